@@ -11,6 +11,7 @@ import (
 	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // time zones for hibernation schedules in minimal images
 
 	"github.com/Payback159/tenama/internal/handlers"
 	"github.com/Payback159/tenama/internal/models"
@@ -20,9 +21,13 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/util/homedir"
 )
 
@@ -107,6 +112,47 @@ func convertConfigResourcesToResourceList(res *models.Resources) (v1.ResourceLis
 	return rl, nil
 }
 
+// parseDurations converts a list of config duration strings to time.Duration values
+func parseDurations(values []string) ([]time.Duration, error) {
+	durations := make([]time.Duration, 0, len(values))
+	for _, value := range values {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		durations = append(durations, d)
+	}
+	return durations, nil
+}
+
+// newIdleDetector creates the idle detector from the idle detection config
+func newIdleDetector(cfg *models.Config, watcher *handlers.NamespaceWatcher, clientset *kubernetes.Clientset) (*handlers.IdleDetector, error) {
+	interval := 5 * time.Minute
+	if cfg.IdleDetection.Interval != "" {
+		var err error
+		interval, err = time.ParseDuration(cfg.IdleDetection.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval: %w", err)
+		}
+	}
+
+	threshold, err := time.ParseDuration(cfg.IdleDetection.Threshold)
+	if err != nil {
+		return nil, fmt.Errorf("invalid threshold: %w", err)
+	}
+
+	action := strings.ToLower(cfg.IdleDetection.Action)
+	switch action {
+	case "":
+		action = handlers.IdleActionWarn
+	case handlers.IdleActionWarn, handlers.IdleActionDelete:
+	default:
+		return nil, fmt.Errorf("invalid action %q, must be %q or %q", cfg.IdleDetection.Action, handlers.IdleActionWarn, handlers.IdleActionDelete)
+	}
+
+	return handlers.NewIdleDetector(watcher, clientset.CoreV1(), interval, threshold, action, cfg.IdleDetection.RecentPodCreation), nil
+}
+
 func main() {
 	// consts
 	const cfgPath = "./config/config.yaml"
@@ -161,6 +207,22 @@ func main() {
 	}
 	c.SetBasicAuthUserList(cfg)
 
+	// The kubeconfigs of new namespaces use the same endpoint and certificate authority as tenama
+	tlsConfig := rest.CopyConfig(config)
+	if err := rest.LoadTLSFiles(tlsConfig); err != nil {
+		slog.Error("Could not read the certificate authority of the cluster", "error", err)
+		os.Exit(1)
+	}
+	c.SetCluster(config.Host, tlsConfig.CAData)
+
+	// The manifest bundle may contain any namespaced kind, including custom resources
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		slog.Error("Could not create dynamic k8s client", "error", err)
+		os.Exit(1)
+	}
+	c.SetDynamicClient(dynamicClient, restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery())))
+
 	// Start event-based namespace watcher for lifecycle management
 	namespaceWatcher := handlers.NewNamespaceWatcher(clientset.CoreV1(), cfg.Namespace.Prefix)
 
@@ -183,14 +245,104 @@ func main() {
 			"storage", storageLimit.String())
 	}
 
+	// Configure pre-expiry notifications if enabled
+	if cfg.Notifications.Enabled {
+		warningOffsets, err := parseDurations(cfg.Notifications.WarningOffsets)
+		if err != nil {
+			slog.Error("Failed to parse notification warning offsets", "error", err)
+			os.Exit(1)
+		}
+		webhookTimeout := 10 * time.Second
+		if cfg.Notifications.Webhook.Timeout != "" {
+			webhookTimeout, err = time.ParseDuration(cfg.Notifications.Webhook.Timeout)
+			if err != nil {
+				slog.Error("Failed to parse webhook timeout", "error", err)
+				os.Exit(1)
+			}
+		}
+		notifier := handlers.NewWebhookNotifier(cfg.Notifications.Webhook.URL, cfg.Notifications.Webhook.Secret, webhookTimeout)
+		namespaceWatcher.SetNotifier(notifier, warningOffsets)
+		slog.Info("Pre-expiry notifications enabled", "url", cfg.Notifications.Webhook.URL, "offsets", cfg.Notifications.WarningOffsets)
+	}
+
+	// Configure pre-delete hooks if any are set
+	if len(cfg.Hooks.PreDelete) > 0 {
+		hooks, err := handlers.NewHookRunner(cfg.Hooks.PreDelete)
+		if err != nil {
+			slog.Error("Failed to configure pre-delete hooks", "error", err)
+			os.Exit(1)
+		}
+		namespaceWatcher.SetPreDeleteHooks(hooks)
+		c.SetPreDeleteHooks(hooks)
+		slog.Info("Pre-delete hooks enabled", "count", len(cfg.Hooks.PreDelete))
+	}
+
+	// Configure soft-deletion of expired namespaces if a grace period is set
+	if cfg.Namespace.GracePeriod != "" {
+		gracePeriod, err := time.ParseDuration(cfg.Namespace.GracePeriod)
+		if err != nil {
+			slog.Error("Failed to parse namespace grace period", "error", err)
+			os.Exit(1)
+		}
+		namespaceWatcher.SetGracePeriod(clientset.AppsV1(), gracePeriod)
+		slog.Info("Soft-deletion of expired namespaces enabled", "gracePeriod", gracePeriod.String())
+	}
+
+	// Configure scheduled hibernation if enabled
+	if cfg.Namespace.Hibernation.Enabled {
+		h := cfg.Namespace.Hibernation
+		if err := namespaceWatcher.SetHibernation(clientset.AppsV1(), h.Hibernate, h.WakeUp, h.TimeZone); err != nil {
+			slog.Error("Failed to parse hibernation schedule", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Scheduled hibernation enabled", "hibernate", h.Hibernate, "wakeUp", h.WakeUp, "timeZone", h.TimeZone)
+	}
+	if cfg.GlobalLimits.HibernatedCapacity != nil {
+		namespaceWatcher.SetHibernatedCapacity(*cfg.GlobalLimits.HibernatedCapacity)
+	}
+
 	// Attach watcher to container for use in handlers
 	c.SetWatcher(namespaceWatcher)
 
+	// With leader election, namespaces are only cleaned up once this replica leads
+	var leaderElector *leaderelection.LeaderElector
+	if cfg.LeaderElection.Enabled {
+		identity, err := os.Hostname()
+		if err != nil {
+			slog.Error("Failed to determine leader election identity", "error", err)
+			os.Exit(1)
+		}
+		leaderElector, err = handlers.NewLeaderElector(clientset.CoordinationV1(), cfg.LeaderElection, identity, namespaceWatcher)
+		if err != nil {
+			slog.Error("Failed to configure leader election", "error", err)
+			os.Exit(1)
+		}
+		namespaceWatcher.SetLeading(context.Background(), false)
+		slog.Info("Leader election enabled", "identity", identity)
+	}
+
 	if err := namespaceWatcher.Start(context.Background()); err != nil {
 		slog.Error("Failed to start namespace watcher", "error", err)
 		os.Exit(1)
 	}
 
+	leaderCtx, stopLeaderElection := context.WithCancel(context.Background())
+	defer stopLeaderElection()
+	if leaderElector != nil {
+		go handlers.RunLeaderElection(leaderCtx, leaderElector)
+	}
+
+	// Start idle detection if enabled
+	var idleDetector *handlers.IdleDetector
+	if cfg.IdleDetection.Enabled {
+		idleDetector, err = newIdleDetector(cfg, namespaceWatcher, clientset)
+		if err != nil {
+			slog.Error("Failed to configure idle detection", "error", err)
+			os.Exit(1)
+		}
+		idleDetector.Start(context.Background())
+	}
+
 	// create new echo instance and register authenticated group
 	e := echo.New()
 	e.HideBanner = true
@@ -245,16 +397,44 @@ func main() {
 	e.Static("/", "web/swagger/")
 
 	// CreateNamespace - Create a new namespace
-	ag.POST("", c.CreateNamespace)
+	ag.POST("", c.CreateNamespace, c.IdempotencyMiddleware)
 
 	// DeleteNamespace - Deletes a namespace
 	ag.DELETE("/:namespace", c.DeleteNamespace)
 
+	// ExtendNamespace - Extends the lifetime of a namespace
+	ag.PATCH("/:namespace/extend", c.ExtendNamespace)
+
+	// RestoreNamespace - Restores an expired namespace within its grace period
+	ag.POST("/:namespace/restore", c.RestoreNamespace)
+
+	// CloneNamespace - Creates a new namespace like an existing one
+	ag.POST("/:namespace/clone", c.CloneNamespace, c.IdempotencyMiddleware)
+
 	// GetNamespaceList - List all namespaces
 	ag.GET("", c.GetNamespaces)
 	// GetNamespaceByName - Find namespace by name
 	ag.GET("/:namespace", c.GetNamespaceByName)
 
+	// GetTemplates - Lists the namespace templates the user is allowed to select
+	tg := e.Group("/templates")
+	tg.Use(middleware.BasicAuth(c.BasicAuthValidator))
+	tg.GET("", c.GetTemplates)
+
+	// Namespace groups - Namespaces that are created, extended and deleted together
+	gg := e.Group("/groups")
+	gg.Use(middleware.BasicAuth(c.BasicAuthValidator))
+	gg.POST("", c.CreateNamespaceGroup, c.IdempotencyMiddleware)
+	gg.GET("/:group", c.GetNamespaceGroup)
+	gg.PATCH("/:group/extend", c.ExtendNamespaceGroup)
+	gg.POST("/:group/restore", c.RestoreNamespaceGroup)
+	gg.DELETE("/:group", c.DeleteNamespaceGroup)
+
+	// GetOperation - Reports the progress of an asynchronous namespace creation
+	og := e.Group("/operations")
+	og.Use(middleware.BasicAuth(c.BasicAuthValidator))
+	og.GET("/:id", c.GetOperation)
+
 	e.GET("/info", c.GetBuildInfo)
 	e.GET("/healthz", c.LivenessProbe)
 	e.GET("/readiness", c.ReadinessProbe)
@@ -265,6 +445,11 @@ func main() {
 	go func() {
 		<-sigChan
 		slog.Info("Shutdown signal received, stopping namespace watcher...")
+		// releases the lease so that another replica takes over right away
+		stopLeaderElection()
+		if idleDetector != nil {
+			idleDetector.Stop()
+		}
 		namespaceWatcher.Stop()
 		slog.Info("Namespace watcher stopped, shutting down server...")
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -5,16 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"math/rand"
 	"net/http"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Payback159/tenama/internal/models"
 	"github.com/labstack/echo/v4"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -29,16 +32,22 @@ const separationString = "-"
 const generatedDefaulfSuffixLength = 5
 const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
 
+// minTokenExpiration is the shortest lifetime the API server accepts for service account tokens
+const minTokenExpiration = 10 * time.Minute
+
+// creatorAnnotation records the authenticated user that requested a namespace
+const creatorAnnotation = "tenama/creator"
+
 var seededRand = rand.New(rand.NewSource(time.Now().UnixNano()))
 
 // generic parser for json requests with echo context and return a models.Namespace struct
-func (c *Container) parseNamespaceRequest(ctx echo.Context) models.Namespace {
+func (c *Container) parseNamespaceRequest(ctx echo.Context) (models.Namespace, error) {
 	ns := models.Namespace{}
 	if err := ctx.Bind(&ns); err != nil {
 		slog.Error("Error parsing namespace request", "error", err)
-		c.sendErrorResponse(ctx, "", "Error parsing namespace request", http.StatusBadRequest)
+		return ns, err
 	}
-	return ns
+	return ns, nil
 }
 
 // parses different errors from kubernetes and returns a custom error message
@@ -66,6 +75,16 @@ func (c *Container) sendErrorResponse(ctx echo.Context, namespace string, messag
 	return ctx.JSON(status, response)
 }
 
+// sendRequestError responds with the status and message of an *echo.HTTPError, other
+// errors are internal server errors
+func (c *Container) sendRequestError(ctx echo.Context, namespace string, err error) error {
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return c.sendErrorResponse(ctx, namespace, fmt.Sprint(he.Message), he.Code)
+	}
+	return c.sendErrorResponse(ctx, namespace, err.Error(), http.StatusInternalServerError)
+}
+
 // formatResourceQuantity formats a resource quantity for display, showing "not set" if missing
 func formatResourceQuantity(rl v1.ResourceList, resourceName v1.ResourceName) string {
 	if q, ok := rl[resourceName]; ok {
@@ -74,71 +93,81 @@ func formatResourceQuantity(rl v1.ResourceList, resourceName v1.ResourceName) st
 	return "not set"
 }
 
+// asyncRequested reports whether the async query parameter asks for an asynchronous creation
+func asyncRequested(ctx echo.Context) (bool, error) {
+	value := ctx.QueryParam("async")
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
 // CreateNamespace - Create a new namespace
 func (c *Container) CreateNamespace(ctx echo.Context) error {
-	namespaceList, _ := getNamespaceList(c.clientset)
-	ns := c.parseNamespaceRequest(ctx)
-	nsSpec, _ := c.craftNamespaceSpecification(&ns, ctx)
-	if !existsNamespace(namespaceList, nsSpec.ObjectMeta.Name) {
-		// Check and reserve global resource limits if enabled (atomic operation)
-		if c.config.GlobalLimits.Enabled && c.watcher != nil {
-			// Convert requested resources to v1.ResourceList
-			var requestedResources v1.ResourceList
-			var err error
-			if ns.Resources != nil {
-				requestedResources, err = ns.Resources.MarshalToResourceList()
+	async, err := asyncRequested(ctx)
+	if err != nil {
+		return c.sendErrorResponse(ctx, "", "Invalid value for async: "+ctx.QueryParam("async"), http.StatusBadRequest)
+	}
+	ns, err := c.parseNamespaceRequest(ctx)
+	if err != nil {
+		return c.sendErrorResponse(ctx, "", "Error parsing namespace request", http.StatusBadRequest)
+	}
+	return c.createNamespaceFromRequest(ctx, ns, async, nil)
+}
+
+// createNamespaceFromRequest checks a namespace request against the templates, the policies
+// and the global limits and provisions the namespace. Clone is nil unless the objects of
+// another namespace are copied into the new one.
+func (c *Container) createNamespaceFromRequest(ctx echo.Context, ns models.Namespace, async bool, clone *cloneSource) error {
+	namespaceList, err := getNamespaceList(c.clientset)
+	if err != nil {
+		slog.Error("Error listing namespaces", "error", err)
+		return c.sendErrorResponse(ctx, "", "Error listing namespaces", http.StatusInternalServerError)
+	}
+	nsSpec, err := c.prepareNamespace(ctx, &ns)
+	if err != nil {
+		return c.sendRequestError(ctx, "", err)
+	}
+	// a taken random name is replaced while provisioning
+	if c.randomName(&ns) || !existsNamespace(namespaceList, nsSpec.ObjectMeta.Name) {
+		if err := c.checkGlobalLimits(ns.Resources); err != nil {
+			return c.sendRequestError(ctx, nsSpec.ObjectMeta.Name, err)
+		}
+
+		// in async mode the progress and the final name are reported through the operation
+		if async {
+			spec := nsSpec.DeepCopy()
+			id := c.operations.Create(authenticatedUser(ctx), nsSpec.Name)
+			go func() {
+				result, err := c.provisionNamespace(context.Background(), spec, &ns, clone, namespaceList, c.operations.reporter(id, spec))
 				if err != nil {
-					slog.Error("Error parsing requested resources", "error", err)
-					return c.sendErrorResponse(ctx, nsSpec.ObjectMeta.Name, "Invalid resource format: "+err.Error(), http.StatusBadRequest)
+					slog.Error("Error creating namespace", "namespace", spec.Name, "operation", id, "error", err)
 				}
+				c.operations.finish(id, result, err)
+			}()
+
+			ctx.Response().Header().Set(echo.HeaderLocation, "/operations/"+id)
+			response := models.PostNamespace202Response{
+				Message:     "Namespace creation accepted",
+				Namespace:   nsSpec.Name,
+				OperationID: id,
 			}
-
-			// Check if namespace creation would exceed global limits
-			// NOTE: The actual reservation happens when the watcher receives the ADDED event
-			if !c.watcher.CanCreateNamespace(requestedResources) {
-				currentUsage := c.watcher.GetCurrentResourceUsage()
-				limits := c.watcher.GetGlobalLimits()
-
-				errorMsg := fmt.Sprintf(
-					"Global resource limits exceeded. Current usage: CPU=%s Memory=%s Storage=%s, Limits: CPU=%s Memory=%s Storage=%s",
-					formatResourceQuantity(currentUsage, v1.ResourceCPU),
-					formatResourceQuantity(currentUsage, v1.ResourceMemory),
-					formatResourceQuantity(currentUsage, v1.ResourceStorage),
-					formatResourceQuantity(limits, v1.ResourceCPU),
-					formatResourceQuantity(limits, v1.ResourceMemory),
-					formatResourceQuantity(limits, v1.ResourceStorage),
-				)
-				slog.Warn("Namespace creation rejected due to resource limits", "error", errorMsg)
-				return c.sendErrorResponse(ctx, nsSpec.ObjectMeta.Name, errorMsg, http.StatusTooManyRequests)
-			}
+			return ctx.JSON(http.StatusAccepted, response)
 		}
 
-		// create namespace
-		c.createNamespace(ctx, c.clientset, nsSpec, namespaceList)
-
-		trb := c.craftTenamaRoleBinding(nsSpec.ObjectMeta.Name, "tenama")
-		c.createRolebinding(ctx, c.clientset, trb, nsSpec.ObjectMeta.Name)
-
-		quotaSpec := c.craftNamespaceQuotaSpecification(nsSpec.ObjectMeta.Name)
-		c.createNamespaceQuota(ctx, c.clientset, quotaSpec, nsSpec.ObjectMeta.Name)
-
-		serviceAccountSpec := c.craftServiceAccountSpecification(nsSpec.ObjectMeta.Name)
-		c.createServiceAccount(ctx, c.clientset, serviceAccountSpec, nsSpec.ObjectMeta.Name)
-
-		rbSpec, _ := c.craftUserRolebindings(nsSpec.ObjectMeta.Name, ns.Users, serviceAccountSpec.ObjectMeta.Name)
-		c.createRolebinding(ctx, c.clientset, rbSpec, nsSpec.ObjectMeta.Name)
-
-		serviceAccountTokenSecret := c.craftServiceAccountTokenSecretSpecificationn(nsSpec.ObjectMeta.Name)
-		secret := c.createSecretForServiceAccountToken(ctx, c.clientset, serviceAccountTokenSecret, nsSpec.ObjectMeta.Name)
-
-		kubeconfig := c.GetKubeconfig(ctx, nsSpec.ObjectMeta.Name, secret)
-		//convert kubeconfig to valide yaml configuration and return it as yaml response
-		kubeconfigYaml := c.convertKubeconfigToYaml(ctx, nsSpec.ObjectMeta.Name, kubeconfig)
+		// create the namespace and its objects as one unit, failures are rolled back
+		result, err := c.provisionNamespace(context.TODO(), nsSpec, &ns, clone, namespaceList, nil)
+		if err != nil {
+			slog.Error("Error creating namespace", "namespace", nsSpec.Name, "error", err)
+			return c.sendErrorResponse(ctx, nsSpec.ObjectMeta.Name, "Error creating namespace: "+err.Error(), provisioningErrorStatus(err))
+		}
 
 		response := models.PostNamespace200Response{
 			Message:    "Namespace created",
 			Namespace:  nsSpec.ObjectMeta.Name,
-			KubeConfig: kubeconfigYaml,
+			KubeConfig: result.kubeconfig,
+			Bundle:     result.bundle,
+			Cloned:     result.cloned,
 		}
 		return ctx.JSON(http.StatusOK, response)
 
@@ -146,6 +175,122 @@ func (c *Container) CreateNamespace(ctx echo.Context) error {
 	return c.sendErrorResponse(ctx, nsSpec.ObjectMeta.Name, "Namespace already exists", http.StatusConflict)
 }
 
+// prepareNamespace checks a namespace request against the templates and the policies,
+// fills in the defaults and crafts the namespace. Rejected requests are returned as
+// *echo.HTTPError with the status and message of the response.
+func (c *Container) prepareNamespace(ctx echo.Context, ns *models.Namespace) (*v1.Namespace, error) {
+	tmpl, err := c.templateFor(ns.Template, authenticatedUser(ctx))
+	switch {
+	case errors.Is(err, errTemplateNotAllowed):
+		slog.Warn("Template rejected", "template", ns.Template, "user", authenticatedUser(ctx))
+		return nil, echo.NewHTTPError(http.StatusForbidden, "Template "+ns.Template+" is not allowed")
+	case err != nil:
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Template "+ns.Template+" not found")
+	}
+	allowedLevels := allowedPodSecurityLevels(c.config.Namespace.PodSecurity, tmpl, authenticatedUser(ctx), authenticatedGroups(ctx))
+	if err := checkPodSecurity(ns.PodSecurity, allowedLevels); err != nil {
+		slog.Warn("Pod security levels rejected", "user", authenticatedUser(ctx), "error", err)
+		if errors.Is(err, errPodSecurityLevelNotAllowed) {
+			return nil, echo.NewHTTPError(http.StatusForbidden, "Pod security level "+ns.PodSecurity.Enforce+" is not allowed, allowed levels are: "+strings.Join(allowedLevels, ", "))
+		}
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid pod security level: "+err.Error())
+	}
+	allowedRoles := c.allowedRoles(tmpl)
+	if err := checkUsers(ns.Users, allowedRoles); err != nil {
+		slog.Warn("Users rejected", "user", authenticatedUser(ctx), "error", err)
+		if errors.Is(err, errRoleNotAllowed) {
+			return nil, echo.NewHTTPError(http.StatusForbidden, "Invalid users: "+err.Error()+", allowed roles are: "+strings.Join(allowedRoles, ", "))
+		}
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid users: "+err.Error())
+	}
+	policy, err := durationPolicyFor(c.config, authenticatedUser(ctx))
+	if err != nil {
+		slog.Error("Invalid duration policy", "error", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Invalid duration policy")
+	}
+	if ns.Duration == "" && tmpl != nil && tmpl.Duration != "" {
+		ns.Duration = tmpl.Duration
+	}
+	if ns.Duration == "" && policy.def > 0 {
+		ns.Duration = policy.def.String()
+	}
+	now := time.Now()
+	expiresAt, err := parseExpiry(ns.Duration, now)
+	if err != nil {
+		slog.Warn("Error parsing duration", "duration", ns.Duration, "error", err)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Error parsing duration: "+err.Error())
+	}
+	if err := policy.check(expiresAt.Sub(now)); err != nil {
+		slog.Warn("Namespace duration rejected", "duration", ns.Duration, "error", err)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if ns.Hibernation != nil {
+		if !c.config.Namespace.Hibernation.Enabled {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Hibernation is disabled")
+		}
+		if err := validateHibernation(ns.Hibernation); err != nil {
+			slog.Warn("Invalid hibernation schedule", "error", err)
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid hibernation schedule: "+err.Error())
+		}
+	}
+	// the quota and the global accounting both use the resulting resources
+	ns.Resources, err = c.namespaceResources(ns.Resources, tmpl)
+	if err != nil {
+		slog.Warn("Requested resources rejected", "user", authenticatedUser(ctx), "error", err)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid resources: "+err.Error())
+	}
+	nsSpec, err := c.craftNamespaceSpecification(ns, tmpl, ctx)
+	if err != nil {
+		var he *echo.HTTPError
+		if errors.As(err, &he) {
+			return nil, he
+		}
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid namespace request: "+err.Error())
+	}
+	return nsSpec, nil
+}
+
+// checkGlobalLimits rejects resources that would exceed the global limits with an
+// *echo.HTTPError. The actual reservation happens when the watcher receives the ADDED event.
+func (c *Container) checkGlobalLimits(resources ...*models.ResourceRequest) error {
+	if !c.config.GlobalLimits.Enabled || c.watcher == nil {
+		return nil
+	}
+
+	// namespaces created together are checked as one unit
+	requestedResources := v1.ResourceList{}
+	for _, r := range resources {
+		rl, err := r.MarshalToResourceList()
+		if err != nil {
+			slog.Error("Error parsing requested resources", "error", err)
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid resource format: "+err.Error())
+		}
+		for name, quantity := range rl {
+			total := requestedResources[name]
+			total.Add(quantity)
+			requestedResources[name] = total
+		}
+	}
+
+	if c.watcher.CanCreateNamespace(requestedResources) {
+		return nil
+	}
+	currentUsage := c.watcher.GetCurrentResourceUsage()
+	limits := c.watcher.GetGlobalLimits()
+
+	errorMsg := fmt.Sprintf(
+		"Global resource limits exceeded. Current usage: CPU=%s Memory=%s Storage=%s, Limits: CPU=%s Memory=%s Storage=%s",
+		formatResourceQuantity(currentUsage, v1.ResourceCPU),
+		formatResourceQuantity(currentUsage, v1.ResourceMemory),
+		formatResourceQuantity(currentUsage, v1.ResourceStorage),
+		formatResourceQuantity(limits, v1.ResourceCPU),
+		formatResourceQuantity(limits, v1.ResourceMemory),
+		formatResourceQuantity(limits, v1.ResourceStorage),
+	)
+	slog.Warn("Namespace creation rejected due to resource limits", "error", errorMsg)
+	return echo.NewHTTPError(http.StatusTooManyRequests, errorMsg)
+}
+
 // DeleteNamespace - Deletes a namespace
 func (c *Container) DeleteNamespace(ctx echo.Context) error {
 	// get existing ns
@@ -153,14 +298,25 @@ func (c *Container) DeleteNamespace(ctx echo.Context) error {
 
 	if !strings.HasPrefix(namespace, c.config.Namespace.Prefix) {
 		slog.Info("Namespace does not start with prefix", "namespace", namespace, "prefix", c.config.Namespace.Prefix)
-		c.sendErrorResponse(ctx, namespace, "Namespace does not start with prefix "+c.config.Namespace.Prefix, http.StatusBadRequest)
+		return c.sendErrorResponse(ctx, namespace, "Namespace does not start with prefix "+c.config.Namespace.Prefix, http.StatusBadRequest)
+	}
+
+	ns, err := c.clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	if err == nil && ns.Labels["created-by"] == "tenama" {
+		// the namespaces of a group share their lifecycle
+		if group := ns.Labels[groupLabel]; group != "" {
+			return c.sendErrorResponse(ctx, namespace, "Namespace belongs to group "+group+", delete the group instead", http.StatusConflict)
+		}
+		if c.hooks != nil {
+			c.hooks.RunPreDelete(ns, HookTriggerAPI)
+		}
 	}
 
 	slog.Info("Delete namespace through an API call", "namespace", namespace)
-	err := c.clientset.CoreV1().Namespaces().Delete(context.TODO(), namespace, metav1.DeleteOptions{})
+	err = c.clientset.CoreV1().Namespaces().Delete(context.TODO(), namespace, metav1.DeleteOptions{})
 	if err != nil {
 		slog.Error("Error deleting namespace", "error", err)
-		c.sendErrorResponse(ctx, namespace, "Namespace not found", http.StatusInternalServerError)
+		return c.sendErrorResponse(ctx, namespace, "Namespace not found", http.StatusInternalServerError)
 	}
 
 	return c.sendErrorResponse(ctx, namespace, "Namespace successfully deleted", http.StatusOK)
@@ -216,65 +372,46 @@ func (c *Container) GetNamespaceByName(ctx echo.Context) error {
 	return c.sendErrorResponse(ctx, namespace, "Namespace successfully found", http.StatusOK)
 }
 
-// convertKubeconfigToYaml
-func (c *Container) convertKubeconfigToYaml(ctx echo.Context, namespace string, kubeconfig *clientcmdapi.Config) []byte {
-	var kubeconfigYaml []byte
-	var err error
-	if kubeconfigYaml, err = clientcmd.Write(*kubeconfig); err != nil {
-		slog.Error("Error converting kubeconfig to yaml", "error", err)
-		c.sendErrorResponse(ctx, namespace, "Error converting kubeconfig to yaml", http.StatusInternalServerError)
+// convertKubeconfigToYaml converts the kubeconfig into its yaml representation
+func convertKubeconfigToYaml(kubeconfig *clientcmdapi.Config) ([]byte, error) {
+	kubeconfigYaml, err := clientcmd.Write(*kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert kubeconfig to yaml: %w", err)
 	}
-	return kubeconfigYaml
+	return kubeconfigYaml, nil
 }
 
-// get secret name with service account token for a given namespace and generate a kubeconfigiuration
-func (c *Container) GetKubeconfig(ctx echo.Context, namespace string, secret *v1.Secret) *clientcmdapi.Config {
-	serviceAccountSecret, err := c.clientset.CoreV1().Secrets(namespace).Get(context.TODO(), secret.Name, metav1.GetOptions{})
+// namespaceKubeconfig returns the kubeconfig of the service account of a namespace
+// with a token that expires at expiresAt
+func (c *Container) namespaceKubeconfig(ctx context.Context, namespace string, expiresAt time.Time) ([]byte, error) {
+	serviceAccountName := c.craftServiceAccountSpecification(namespace).Name
+	token, err := c.createServiceAccountToken(ctx, namespace, serviceAccountName, expiresAt)
 	if err != nil {
-		slog.Error("Error getting service account token secret", "error", err)
-		c.sendErrorResponse(ctx, namespace, "Error getting service account token secret", http.StatusInternalServerError)
-		return nil
-	}
-	kubeconfig := c.craftKubeconfig(ctx, namespace, serviceAccountSecret)
-	if err != nil {
-		slog.Error("Error crafting kubeconfig", "error", err)
-		c.sendErrorResponse(ctx, namespace, "Error crafting kubeconfig", http.StatusInternalServerError)
-		return nil
+		return nil, err
 	}
-	return kubeconfig
+	return convertKubeconfigToYaml(c.craftKubeconfig(namespace, serviceAccountName, token))
 }
 
-// get namespace and service account token secret name for a given namespace
-// craft a kubeconfig and return it
-func (c *Container) craftKubeconfig(ctx echo.Context, namespace string, secret *v1.Secret) *clientcmdapi.Config {
+// craft a kubeconfig for the service account of a namespace and return it
+func (c *Container) craftKubeconfig(namespace string, serviceAccountName string, token string) *clientcmdapi.Config {
 	clusterName := "default"
-	// get cluster endpoint
-	clusterEndpoint := c.clientset.CoreV1().RESTClient().Get().URL().Host
-	// get cluster certificate authority data
-	clusterCertificateAuthorityData := secret.Data["ca.crt"]
-	// get service account token
-	serviceAccountToken := secret.Data["token"]
-	// get service account name
-	serviceAccountName := secret.Annotations["kubernetes.io/service-account.name"]
-	// get service account namespace
-	serviceAccountNamespace := secret.Namespace
 
 	// create a kubeconfig
 	kubeconfig := clientcmdapi.NewConfig()
 	// set cluster
 	kubeconfig.Clusters[clusterName] = &clientcmdapi.Cluster{
-		Server:                   clusterEndpoint,
-		CertificateAuthorityData: clusterCertificateAuthorityData,
+		Server:                   c.clusterServer,
+		CertificateAuthorityData: c.clusterCA,
 	}
 	// set auth info
 	kubeconfig.AuthInfos[serviceAccountName] = &clientcmdapi.AuthInfo{
-		Token: string(serviceAccountToken),
+		Token: token,
 	}
 	// set context
 	kubeconfig.Contexts[serviceAccountName] = &clientcmdapi.Context{
 		Cluster:   clusterName,
 		AuthInfo:  serviceAccountName,
-		Namespace: serviceAccountNamespace,
+		Namespace: namespace,
 	}
 	// set current context
 	kubeconfig.CurrentContext = serviceAccountName
@@ -304,26 +441,38 @@ func (c *Container) craftTenamaRoleBinding(namespace string, serviceAccountName
 	}
 }
 
-func (c *Container) craftUserRolebindings(namespace string, users []string, serviceAccountName string) (*rbacv1.RoleBinding, error) {
-	rb := &rbacv1.RoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      namespace + "troubleshooters",
-			Namespace: namespace,
-		},
-		Subjects: []rbacv1.Subject{},
-		RoleRef: rbacv1.RoleRef{
-			APIGroup: "rbac.authorization.k8s.io",
-			Kind:     "ClusterRole",
-			Name:     role,
-		},
+// craft one rolebinding per clusterrole that binds the requested users to it. Users without
+// a role get the given clusterrole, whose rolebinding also binds the returned service account
+// so that it can access the namespace.
+func (c *Container) craftUserRolebindings(namespace string, users []models.NamespaceUser, serviceAccountName string, clusterRole string) []*rbacv1.RoleBinding {
+	craft := func(name string, role string) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Subjects: []rbacv1.Subject{},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     role,
+			},
+		}
 	}
 
+	rb := craft(namespace+"troubleshooters", clusterRole)
+	bindings := []*rbacv1.RoleBinding{rb}
+	byRole := map[string]*rbacv1.RoleBinding{clusterRole: rb}
 	for _, user := range users {
-		rb.Subjects = append(rb.Subjects, rbacv1.Subject{
-			Kind:     rbacv1.UserKind,
-			APIGroup: rbacv1.GroupName,
-			Name:     user,
-		})
+		role := user.Role
+		if role == "" {
+			role = clusterRole
+		}
+		if _, ok := byRole[role]; !ok {
+			byRole[role] = craft(namespace+"troubleshooters-"+role, role)
+			bindings = append(bindings, byRole[role])
+		}
+		byRole[role].Subjects = append(byRole[role].Subjects, userSubject(user))
 	}
 
 	// add ServiceAccount that is returned to the caller so that it can access the namespace
@@ -332,22 +481,26 @@ func (c *Container) craftUserRolebindings(namespace string, users []string, serv
 		Name: serviceAccountName,
 	})
 
-	return rb, nil
+	return bindings
 }
 
-func (c *Container) createRolebinding(ctx echo.Context, clientset *kubernetes.Clientset, rb *rbacv1.RoleBinding, ns string) {
-	slog.Debug("Creating binding for service account", "binding", rb.Name, "subjects", rb.Subjects[:len(rb.Subjects)-1], "namespace", ns)
-	rb, err := clientset.RbacV1().RoleBindings(ns).Create(context.TODO(), rb, metav1.CreateOptions{})
-	if err != nil {
-		slog.Error("Error creating rolebinding", "error", err)
-		c.sendErrorResponse(ctx, ns, "Error creating rolebinding", http.StatusInternalServerError)
+func (c *Container) createRolebinding(ctx context.Context, rb *rbacv1.RoleBinding) error {
+	slog.Debug("Creating binding for service account", "binding", rb.Name, "subjects", rb.Subjects, "namespace", rb.Namespace)
+	if _, err := c.clientset.RbacV1().RoleBindings(rb.Namespace).Create(ctx, rb, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create rolebinding %s: %w", rb.Name, err)
 	}
+	return nil
 }
 
-// Checks if resource values are set in the config file and
-// crafts a ResourceQuota for the namespace
-func (c *Container) craftNamespaceQuotaSpecification(namespace string) *v1.ResourceQuota {
+// Checks if resource values are set in the template or the config file and
+// crafts a ResourceQuota for the namespace. The requested resources, which may
+// be nil, replace the request quotas.
+func (c *Container) craftNamespaceQuotaSpecification(namespace string, tmpl *models.NamespaceTemplate, requested *models.ResourceRequest) *v1.ResourceQuota {
 	slog.Debug("Crafting quota for the namespace", "namespace", namespace)
+	resources := c.config.Namespace.Resources
+	if tmpl != nil && tmpl.Resources != nil {
+		resources = *tmpl.Resources
+	}
 
 	quota := &v1.ResourceQuota{
 		ObjectMeta: metav1.ObjectMeta{
@@ -359,36 +512,41 @@ func (c *Container) craftNamespaceQuotaSpecification(namespace string) *v1.Resou
 		},
 	}
 
-	if c.config.Namespace.Resources.Limits.CPU != "" {
-		namespaceResourcesCPULimit, err := resource.ParseQuantity(c.config.Namespace.Resources.Limits.CPU)
+	if resources.Limits.CPU != "" {
+		namespaceResourcesCPULimit, err := resource.ParseQuantity(resources.Limits.CPU)
 		if err == nil {
 			quota.Spec.Hard[v1.ResourceLimitsCPU] = namespaceResourcesCPULimit
 		}
 	}
-	if c.config.Namespace.Resources.Limits.Memory != "" {
-		namespaceResourcesMemoryLimit, err := resource.ParseQuantity(c.config.Namespace.Resources.Limits.Memory)
+	if resources.Limits.Memory != "" {
+		namespaceResourcesMemoryLimit, err := resource.ParseQuantity(resources.Limits.Memory)
 		if err == nil {
 			quota.Spec.Hard[v1.ResourceLimitsMemory] = namespaceResourcesMemoryLimit
 		}
 	}
-	if c.config.Namespace.Resources.Requests.CPU != "" {
-		namespaceResourcesCPURequest, err := resource.ParseQuantity(c.config.Namespace.Resources.Requests.CPU)
+	if resources.Requests.CPU != "" {
+		namespaceResourcesCPURequest, err := resource.ParseQuantity(resources.Requests.CPU)
 		if err == nil {
 			quota.Spec.Hard[v1.ResourceRequestsCPU] = namespaceResourcesCPURequest
 		}
 	}
-	if c.config.Namespace.Resources.Requests.Memory != "" {
-		namespaceResourcesMemoryRequest, err := resource.ParseQuantity(c.config.Namespace.Resources.Requests.Memory)
+	if resources.Requests.Memory != "" {
+		namespaceResourcesMemoryRequest, err := resource.ParseQuantity(resources.Requests.Memory)
 		if err == nil {
 			quota.Spec.Hard[v1.ResourceRequestsMemory] = namespaceResourcesMemoryRequest
 		}
 	}
-	if c.config.Namespace.Resources.Requests.Storage != "" {
-		namespaceResourcesStorageRequest, err := resource.ParseQuantity(c.config.Namespace.Resources.Requests.Storage)
+	if resources.Requests.Storage != "" {
+		namespaceResourcesStorageRequest, err := resource.ParseQuantity(resources.Requests.Storage)
 		if err == nil {
 			quota.Spec.Hard[v1.ResourceRequestsStorage] = namespaceResourcesStorageRequest
 		}
 	}
+	if requested != nil {
+		applyRequestedQuota(quota.Spec.Hard, v1.ResourceRequestsCPU, v1.ResourceLimitsCPU, requested.CPU)
+		applyRequestedQuota(quota.Spec.Hard, v1.ResourceRequestsMemory, v1.ResourceLimitsMemory, requested.Memory)
+		applyRequestedQuota(quota.Spec.Hard, v1.ResourceRequestsStorage, "", requested.Storage)
+	}
 
 	return quota
 }
@@ -404,118 +562,139 @@ func (c *Container) craftServiceAccountSpecification(namespace string) *v1.Servi
 	}
 }
 
-func (c *Container) createServiceAccount(ctx echo.Context, clientset *kubernetes.Clientset, sa *v1.ServiceAccount, ns string) {
-	slog.Debug("Creating ServiceAccount", "name", sa.Name, "namespace", ns)
-	sa, err := clientset.CoreV1().ServiceAccounts(ns).Create(context.TODO(), sa, metav1.CreateOptions{})
-	if err != nil {
-		slog.Error("Error creating service account", "error", err)
-		c.sendErrorResponse(ctx, ns, "Error creating service account", http.StatusInternalServerError)
-	}
-}
-
-// craft secret for service account token for the crafted ServiceAccount
-func (c *Container) craftServiceAccountTokenSecretSpecificationn(namespace string) *v1.Secret {
-	slog.Debug("Crafting secret for the service account", "namespace", namespace)
-	return &v1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        c.config.Namespace.Prefix + separationString + "sa-token",
-			Namespace:   namespace,
-			Annotations: map[string]string{"kubernetes.io/service-account.name": c.config.Namespace.Prefix + separationString + "sa"},
-		},
-		Type: "kubernetes.io/service-account-token",
+func (c *Container) createServiceAccount(ctx context.Context, sa *v1.ServiceAccount) error {
+	slog.Debug("Creating ServiceAccount", "name", sa.Name, "namespace", sa.Namespace)
+	if _, err := c.clientset.CoreV1().ServiceAccounts(sa.Namespace).Create(ctx, sa, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create service account %s: %w", sa.Name, err)
 	}
+	return nil
 }
 
-func (c *Container) createSecretForServiceAccountToken(ctx echo.Context, clientset *kubernetes.Clientset, secret *v1.Secret, ns string) *v1.Secret {
-	slog.Debug("Creating Secret", "name", secret.Name, "namespace", ns)
-	//Create Token Secret, wait for it to be created and then return it
-
-	secret, err := clientset.CoreV1().Secrets(ns).Create(context.TODO(), secret, metav1.CreateOptions{})
+// createServiceAccountToken requests a token of the service account that expires together
+// with the namespace, so that a leaked kubeconfig cannot be used afterwards
+func (c *Container) createServiceAccountToken(ctx context.Context, namespace string, serviceAccountName string, expiresAt time.Time) (string, error) {
+	expiration := time.Until(expiresAt)
+	// the API server rejects tokens that expire in less than ten minutes
+	if expiration < minTokenExpiration {
+		expiration = minTokenExpiration
+	}
+	seconds := int64(math.Ceil(expiration.Seconds()))
+	slog.Debug("Creating token for the service account", "serviceAccount", serviceAccountName, "namespace", namespace, "expiresAt", expiresAt)
+	tr, err := c.clientset.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, serviceAccountName, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &seconds},
+	}, metav1.CreateOptions{})
 	if err != nil {
-		slog.Error("Error creating secret", "error", err)
-		c.sendErrorResponse(ctx, ns, "Error creating ServiceAccount secret", http.StatusInternalServerError)
-	}
-	//loop until secret has a data field with a token in it
-	// or until timeout is reached (10 seconds) and then return it
-	// or error if timeout is reached before token is created in secret data field
-	timeout := time.After(10 * time.Second)
-	//use ticker to check every 500ms if secret has token in data field
-	ticker := time.NewTicker(500 * time.Millisecond)
-	for {
-		select {
-		case <-timeout:
-			slog.Error("Timeout reached before token was created in secret data field")
-			c.sendErrorResponse(ctx, ns, "timeout reached before token was created in secret data field", http.StatusInternalServerError)
-		case <-ticker.C:
-			secret, err := clientset.CoreV1().Secrets(ns).Get(context.TODO(), secret.Name, metav1.GetOptions{})
-			if err != nil {
-				slog.Error("Error getting secret", "error", err)
-				c.sendErrorResponse(ctx, ns, "Error getting ServiceAccount secret", http.StatusInternalServerError)
-			}
-			if secret.Data["token"] != nil {
-				return secret
-			}
-		}
+		return "", fmt.Errorf("failed to create service account token: %w", err)
 	}
+	if tr.Status.ExpirationTimestamp.Time.Before(expiresAt) {
+		slog.Warn("Service account token expires before the namespace, the API server limits the token lifetime", "namespace", namespace, "tokenExpiresAt", tr.Status.ExpirationTimestamp.Time)
+	}
+	return tr.Status.Token, nil
 }
 
-func (c *Container) createNamespaceQuota(ctx echo.Context, clientset *kubernetes.Clientset, quota *v1.ResourceQuota, ns string) {
-	slog.Debug("Creating quota", "name", quota.Name, "namespace", ns)
-	quota, err := clientset.CoreV1().ResourceQuotas(ns).Create(context.TODO(), quota, metav1.CreateOptions{})
-	if err != nil {
-		slog.Error("Error creating namespace quota", "error", err)
-		c.sendErrorResponse(ctx, ns, "Error creating namespace quota", http.StatusInternalServerError)
+func (c *Container) createNamespaceQuota(ctx context.Context, quota *v1.ResourceQuota) error {
+	slog.Debug("Creating quota", "name", quota.Name, "namespace", quota.Namespace)
+	if _, err := c.clientset.CoreV1().ResourceQuotas(quota.Namespace).Create(ctx, quota, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create resource quota: %w", err)
 	}
+	return nil
 }
 
-func (c *Container) craftNamespaceSpecification(ns *models.Namespace, ctx echo.Context) (*v1.Namespace, error) {
-	var nsn string
-
-	if c.config.Namespace.Prefix == "" {
-		slog.Error("Prefix is not set in config file")
-		return nil, errors.New("prefix is not set in config file")
+// craftLimitRangeSpecification crafts a LimitRange with the container defaults and bounds
+// and, if set, the storage bounds per PersistentVolumeClaim
+func (c *Container) craftLimitRangeSpecification(namespace string, lr *models.LimitRange) *v1.LimitRange {
+	limitRange := &v1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.config.Namespace.Prefix + separationString + "limits",
+			Namespace: namespace,
+		},
+		Spec: v1.LimitRangeSpec{
+			Limits: []v1.LimitRangeItem{
+				{
+					Type:           v1.LimitTypeContainer,
+					Default:        containerResourceList(lr.Default),
+					DefaultRequest: containerResourceList(lr.DefaultRequest),
+					Min:            containerResourceList(lr.Min),
+					Max:            containerResourceList(lr.Max),
+				},
+			},
+		},
 	}
 
-	nsn = c.config.Namespace.Prefix + separationString
-
-	if ns.Infix == "" {
-		slog.Error("Infix is not set in request")
-		return nil, errors.New("infix is not set in request")
+	pvc := lr.PersistentVolumeClaim
+	if pvc.Min != "" || pvc.Max != "" {
+		limitRange.Spec.Limits = append(limitRange.Spec.Limits, v1.LimitRangeItem{
+			Type: v1.LimitTypePersistentVolumeClaim,
+			Min:  storageResourceList(pvc.Min),
+			Max:  storageResourceList(pvc.Max),
+		})
 	}
+	return limitRange
+}
 
-	nsn = nsn + ns.Infix + separationString
-
-	nsn, err := validateAndTransformToK8sName(nsn, []rune(separationString)[0])
-	if err != nil {
-		slog.Error("Error parsing namespace name", "namespace", nsn)
+// containerResourceList converts the set values, invalid ones are rejected by validateLimitRange
+func containerResourceList(cr models.ContainerResources) v1.ResourceList {
+	rl := v1.ResourceList{}
+	if q, err := resource.ParseQuantity(cr.CPU); cr.CPU != "" && err == nil {
+		rl[v1.ResourceCPU] = q
+	}
+	if q, err := resource.ParseQuantity(cr.Memory); cr.Memory != "" && err == nil {
+		rl[v1.ResourceMemory] = q
 	}
+	if len(rl) == 0 {
+		return nil
+	}
+	return rl
+}
 
-	if ns.Suffix != "" {
-		nsn = nsn + separationString + ns.Suffix
-	} else {
-		// generate randomstring for namespace postfix if buildhash is unset, avoiding collisions
-		nsn = nsn + separationString + StringWithCharset(generatedDefaulfSuffixLength, charset)
+func (c *Container) createLimitRange(ctx context.Context, lr *v1.LimitRange) error {
+	slog.Debug("Creating limit range", "name", lr.Name, "namespace", lr.Namespace)
+	if _, err := c.clientset.CoreV1().LimitRanges(lr.Namespace).Create(ctx, lr, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create limit range: %w", err)
 	}
+	return nil
+}
 
-	namespaceDuration, err := time.ParseDuration(ns.Duration)
+func (c *Container) craftNamespaceSpecification(ns *models.Namespace, tmpl *models.NamespaceTemplate, ctx echo.Context) (*v1.Namespace, error) {
+	nsn, err := c.namespaceName(ns, authenticatedUser(ctx))
 	if err != nil {
-		slog.Warn("Error parsing duration", "duration", ns.Duration)
-		c.sendErrorResponse(ctx, nsn, "Error parsing duration", http.StatusBadRequest)
+		slog.Error("Error building namespace name", "error", err)
+		return nil, err
 	}
 
-	ns.Duration = fmt.Sprint(namespaceDuration)
+	now := time.Now()
+	expiresAt, err := parseExpiry(ns.Duration, now)
+	if err != nil {
+		slog.Warn("Error parsing duration", "duration", ns.Duration, "error", err)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Error parsing duration: "+err.Error())
+	}
 
 	podSecurityStandardVersion, err := getK8sServerVersion(c.clientset)
 	if err != nil {
-		slog.Warn("Error getting kubernetes server version", "error", err)
+		slog.Warn("Error getting kubernetes server version, using latest", "error", err)
 	}
 
-	labels := map[string]string{
-		"created-by":                                 "tenama",
-		"tenama/namespace-duration":                  ns.Duration,
-		"pod-security.kubernetes.io/enforce":         "baseline",
-		"pod-security.kubernetes.io/enforce-version": podSecurityStandardVersion,
+	// labels and annotations of the template must not override the ones managed by tenama
+	labels := map[string]string{}
+	annotations := hibernationAnnotations(ns.Hibernation)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if tmpl != nil {
+		for key, value := range tmpl.Labels {
+			labels[key] = value
+		}
+		for key, value := range tmpl.Annotations {
+			if _, ok := annotations[key]; !ok {
+				annotations[key] = value
+			}
+		}
+		labels[templateLabel] = tmpl.Name
+	}
+	for key, value := range podSecurityLabels(ns.PodSecurity, tmpl, podSecurityStandardVersion) {
+		labels[key] = value
 	}
+	labels["created-by"] = "tenama"
 
 	// Add resource labels if provided
 	if ns.Resources != nil {
@@ -532,15 +711,22 @@ func (c *Container) craftNamespaceSpecification(ns *models.Namespace, ctx echo.C
 
 	nsSpec := &v1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   nsn,
-			Labels: labels,
+			Name:        nsn,
+			Labels:      labels,
+			Annotations: annotations,
 		},
 	}
+	// the duration label keeps namespaces working with tenama versions that ignore the annotation
+	setExpiry(nsSpec, now, expiresAt)
+	if creator := authenticatedUser(ctx); creator != "" {
+		nsSpec.Annotations[creatorAnnotation] = creator
+		nsSpec.Labels[requesterLabel] = requesterHash(creator)
+	}
 
-	return nsSpec, err
+	return nsSpec, nil
 }
 
-func getK8sServerVersion(clientset *kubernetes.Clientset) (string, error) {
+func getK8sServerVersion(clientset kubernetes.Interface) (string, error) {
 	information, err := clientset.Discovery().ServerVersion()
 	if err != nil {
 		return "latest", err
@@ -557,115 +743,36 @@ func existsNamespace(namespaceList *v1.NamespaceList, namespace string) bool {
 	return false
 }
 
-func existsNamespaceWithPrefix(namespaceList *v1.NamespaceList, namespacePrefix string) bool {
-	for _, ns := range namespaceList.Items {
-		if strings.Contains(ns.Name, namespacePrefix) {
-			return true
-		}
-	}
-	return false
-}
-
-func getNamespaceList(clientset *kubernetes.Clientset) (*v1.NamespaceList, error) {
+func getNamespaceList(clientset kubernetes.Interface) (*v1.NamespaceList, error) {
 	nl, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
 	return nl, err
 }
 
-func (c *Container) createNamespace(ctx echo.Context, clientset *kubernetes.Clientset, nsSpec *v1.Namespace, namespaceList *v1.NamespaceList) {
-	slog.Info("Considering to create namespace", "namespace", nsSpec.Name)
-	if !existsNamespaceWithPrefix(namespaceList, nsSpec.Name) {
-		_, err := clientset.CoreV1().Namespaces().Create(context.TODO(), nsSpec, metav1.CreateOptions{})
-		if err != nil {
-			slog.Error("Error creating namespace", "namespace", nsSpec.Name, "error", err)
-			c.sendErrorResponse(ctx, nsSpec.ObjectMeta.Name, "Error creating namespace", http.StatusInternalServerError)
+// createNamespace creates the namespace. A name that is already taken is replaced by
+// another one with new random parts, if the name template has any.
+func (c *Container) createNamespace(ctx context.Context, nsSpec *v1.Namespace, req *models.Namespace, namespaceList *v1.NamespaceList) error {
+	for attempt := 1; ; attempt++ {
+		slog.Info("Considering to create namespace", "namespace", nsSpec.Name)
+		err := errNamespaceExists
+		if !existsNamespace(namespaceList, nsSpec.Name) {
+			_, err = c.clientset.CoreV1().Namespaces().Create(ctx, nsSpec, metav1.CreateOptions{})
 		}
-		slog.Info("Created Namespace", "namespace", nsSpec.Name)
-	}
-	slog.Warn("Namespace matching already exists", "namespace", nsSpec.Name)
-}
-
-// replaces k8s invalid chars (separationRune) in inputString
-func validateAndTransformToK8sName(inputString string, separationRune rune) (string, error) {
-	// init
-	var err error
-
-	// pre-validate
-	if inputString == "" {
-		return "", errors.New("parameter namespace is required")
-	}
-
-	// lowercase
-	inputStringLowerCase := strings.ToLower(inputString)
-
-	// replace invalid characters
-	r, _ := regexp.Compile(`[-a-z\\d]`)
-	normalizedNameRunes := []rune("")
-	for _, ch := range inputStringLowerCase {
-		chs := string(ch)
-		if !r.MatchString(chs) {
-			slog.Debug("Namespace contains invalid character",
-				"namespace", inputStringLowerCase,
-				"character", chs,
-				"regex", r.String(),
-				"replacement", string(separationRune))
-			normalizedNameRunes = append(normalizedNameRunes, separationRune)
+		switch {
+		case err == nil:
+			slog.Info("Created Namespace", "namespace", nsSpec.Name)
+			return nil
+		case !errors.Is(err, errNamespaceExists) && !apierrors.IsAlreadyExists(err):
+			return fmt.Errorf("failed to create namespace: %w", err)
+		case !c.randomName(req) || attempt == nameAttempts:
+			slog.Warn("Namespace already exists", "namespace", nsSpec.Name, "attempts", attempt)
+			return fmt.Errorf("%w: %s", errNamespaceExists, nsSpec.Name)
 		}
-		normalizedNameRunes = append(normalizedNameRunes, ch)
-	}
-
-	// truncate too long name
-	// RFC 1123 Label Names
-	// https://kubernetes.io/docs/concepts/overview/working-with-objects/names/#names
-	if len(normalizedNameRunes) > 63 {
-		normalizedNameRunes = normalizedNameRunes[:62]
-	}
-
-	// chomp minuses at beginning
-	normalizedNameRunes = chompBeginningCharacter(normalizedNameRunes, separationRune)
-
-	// chomp minuses at end
-	normalizedNameRunes = chompEndingCharacter(normalizedNameRunes, separationRune)
 
-	// convert rune array to string
-	normalizedNameString := string(normalizedNameRunes)
-
-	// post-validate
-	if normalizedNameString == "" {
-		return "",
-			fmt.Errorf("namespace empty after matching all characters against regex: '%s'", r)
-	}
-
-	return normalizedNameString, err
-}
-
-func chompBeginningCharacter(runearr []rune, runechar rune) []rune {
-	chomping := true
-	var chompedRune []rune
-	for _, cr := range runearr {
-		if chomping && cr == runechar {
-			slog.Debug("Chomping character from string", "character", string(cr), "runechar", string(runechar))
-		} else {
-			chompedRune = append(chompedRune, cr)
-			chomping = false
+		slog.Info("Namespace name is taken, trying another one", "namespace", nsSpec.Name)
+		if nsSpec.Name, err = c.namespaceName(req, nsSpec.Annotations[creatorAnnotation]); err != nil {
+			return fmt.Errorf("failed to build namespace name: %w", err)
 		}
 	}
-	return chompedRune
-}
-
-// chompEndingCharacter removes the ending character from the given rune array recursively.
-// If the rune array is empty, it returns an empty rune array.
-// If the last element of the rune array is equal to the specified rune character,
-// it recursively removes the last element until it finds a different character.
-// It returns the modified rune array.
-func chompEndingCharacter(runearr []rune, runechar rune) []rune {
-	if len(runearr) == 0 {
-		return []rune{}
-	}
-	if runearr[len(runearr)-1] == runechar {
-		return chompEndingCharacter(runearr[:len(runearr)-1], runechar)
-	}
-
-	return runearr
 }
 
 // StringWithCharset generates a random string of the specified length using the characters from the given charset.
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	bundleObjectCreated = "created"
+	bundleObjectFailed  = "failed"
+)
+
+// BundleVars are the variables the manifests of a bundle are rendered with
+type BundleVars struct {
+	Namespace string
+	Prefix    string
+	Infix     string // normalized like in the namespace name, requests cannot inject YAML
+	Creator   string
+	Template  string
+	ExpiresAt string // RFC3339
+}
+
+// bundleFile is a parsed manifest template of the bundle
+type bundleFile struct {
+	name string
+	tmpl *template.Template
+}
+
+// isManifest reports whether a file or config map key holds manifests
+func isManifest(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// parseBundleFiles parses the manifest templates in the order of their names
+func parseBundleFiles(sources map[string]string) ([]bundleFile, error) {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		if isManifest(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	files := make([]bundleFile, 0, len(names))
+	for _, name := range names {
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(sources[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest %s: %w", name, err)
+		}
+		files = append(files, bundleFile{name: name, tmpl: tmpl})
+	}
+	return files, nil
+}
+
+// loadBundleDirectory reads and parses all manifests of a directory
+func loadBundleDirectory(dir string) ([]bundleFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle directory: %w", err)
+	}
+	sources := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isManifest(entry.Name()) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest %s: %w", entry.Name(), err)
+		}
+		sources[entry.Name()] = string(content)
+	}
+	return parseBundleFiles(sources)
+}
+
+// SetDynamicClient sets the clients used to create the objects of the manifest bundle
+func (c *Container) SetDynamicClient(client dynamic.Interface, mapper meta.ResettableRESTMapper) {
+	c.dynamicClient = client
+	c.restMapper = mapper
+}
+
+// bundleFiles returns the manifests of the directory, or reads them from the config map
+func (c *Container) bundleFiles(ctx context.Context) ([]bundleFile, error) {
+	ref := c.config.Namespace.Bundle.ConfigMap
+	if ref == nil {
+		return c.bundle, nil
+	}
+	cm, err := c.clientset.CoreV1().ConfigMaps(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bundle config map %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	return parseBundleFiles(cm.Data)
+}
+
+// bundleEnabled reports whether a manifest bundle is configured
+func (c *Container) bundleEnabled() bool {
+	return c.config.Namespace.Bundle.Directory != "" || c.config.Namespace.Bundle.ConfigMap != nil
+}
+
+// applyBundle renders the manifests and creates their objects in the namespace. Every
+// object is reported on its own, failures do not stop the remaining objects.
+func (c *Container) applyBundle(ctx context.Context, vars BundleVars) []models.BundleObjectStatus {
+	files, err := c.bundleFiles(ctx)
+	if err != nil {
+		slog.Error("Error loading manifest bundle", "namespace", vars.Namespace, "error", err)
+		return []models.BundleObjectStatus{{Status: bundleObjectFailed, Error: err.Error()}}
+	}
+
+	var results []models.BundleObjectStatus
+	for _, file := range files {
+		var rendered bytes.Buffer
+		if err := file.tmpl.Execute(&rendered, vars); err != nil {
+			results = append(results, models.BundleObjectStatus{Source: file.name, Status: bundleObjectFailed, Error: err.Error()})
+			continue
+		}
+
+		decoder := utilyaml.NewYAMLOrJSONDecoder(&rendered, 4096)
+		for {
+			obj := &unstructured.Unstructured{}
+			err := decoder.Decode(&obj.Object)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				results = append(results, models.BundleObjectStatus{Source: file.name, Status: bundleObjectFailed, Error: "invalid manifest: " + err.Error()})
+				break
+			}
+			if len(obj.Object) == 0 {
+				continue
+			}
+			results = append(results, c.createBundleObject(ctx, vars.Namespace, file.name, obj))
+		}
+	}
+	return results
+}
+
+// createBundleObject creates a single object in the namespace. Cluster-scoped objects are
+// rejected, the bundle must not reach beyond the namespace.
+func (c *Container) createBundleObject(ctx context.Context, namespace, source string, obj *unstructured.Unstructured) models.BundleObjectStatus {
+	result := models.BundleObjectStatus{Source: source, Kind: obj.GetKind(), Name: obj.GetName()}
+	fail := func(err error) models.BundleObjectStatus {
+		slog.Warn("Error creating bundle object", "namespace", namespace, "source", source, "kind", result.Kind, "name", result.Name, "error", err)
+		result.Status = bundleObjectFailed
+		result.Error = err.Error()
+		return result
+	}
+	if c.dynamicClient == nil || c.restMapper == nil {
+		return fail(errors.New("dynamic client is not configured"))
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// the kind may belong to a CRD installed after the discovery was cached
+		c.restMapper.Reset()
+		mapping, err = c.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return fail(fmt.Errorf("unknown kind: %w", err))
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return fail(fmt.Errorf("%s is not namespaced", gvk.Kind))
+	}
+
+	obj.SetNamespace(namespace)
+	if _, err := c.dynamicClient.Resource(mapping.Resource).Namespace(namespace).Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+		return fail(err)
+	}
+	result.Status = bundleObjectCreated
+	return result
+}
+
+// bundleVars returns the variables of a namespace for rendering the bundle
+func (c *Container) bundleVars(nsSpec *v1.Namespace, req *models.Namespace) BundleVars {
+	vars := BundleVars{
+		Namespace: nsSpec.Name,
+		Prefix:    c.config.Namespace.Prefix,
+		Infix:     normalizeNamePart(req.Infix),
+		Creator:   nsSpec.Annotations[creatorAnnotation],
+		Template:  req.Template,
+	}
+	if expiresAt, err := namespaceExpiration(nsSpec); err == nil {
+		vars.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	}
+	return vars
+}
+
+// describeBundleFailures lists the failed objects for log messages
+func describeBundleFailures(results []models.BundleObjectStatus) string {
+	var failed []string
+	for _, r := range results {
+		if r.Status == bundleObjectFailed {
+			failed = append(failed, strings.TrimSpace(r.Source+" "+r.Kind+"/"+r.Name))
+		}
+	}
+	return strings.Join(failed, ", ")
+}
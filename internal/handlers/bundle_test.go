@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// staticRESTMapper is a fixed RESTMapper for tests, resetting it changes nothing
+type staticRESTMapper struct {
+	*meta.DefaultRESTMapper
+}
+
+func (staticRESTMapper) Reset() {}
+
+func newBundleTestContainer(t *testing.T, dir string) (*Container, *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	cfg.Namespace.Bundle.Directory = dir
+	bundle, err := loadBundleDirectory(dir)
+	if err != nil {
+		t.Fatalf("loadBundleDirectory returned error: %v", err)
+	}
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot)
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	c := &Container{config: cfg, bundle: bundle}
+	c.SetDynamicClient(client, staticRESTMapper{mapper})
+	return c, client
+}
+
+func writeBundleFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseBundleFiles(t *testing.T) {
+	files, err := parseBundleFiles(map[string]string{
+		"20-second.yaml": "b",
+		"10-first.yml":   "a",
+		"README.md":      "{{ .Broken",
+	})
+	if err != nil {
+		t.Fatalf("parseBundleFiles returned error: %v", err)
+	}
+	if len(files) != 2 || files[0].name != "10-first.yml" || files[1].name != "20-second.yaml" {
+		t.Errorf("Expected manifests in name order, got %v", files)
+	}
+
+	if _, err := parseBundleFiles(map[string]string{"broken.yaml": "{{ .Broken"}); err == nil {
+		t.Error("Expected error for invalid template")
+	}
+}
+
+func TestApplyBundle(t *testing.T) {
+	dir := t.TempDir()
+	writeBundleFile(t, dir, "10-settings.yaml", `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  namespace: "{{ .Namespace }}"
+  creator: "{{ .Creator }}"
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: unknown
+`)
+	writeBundleFile(t, dir, "20-cluster.yaml", `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: escalate
+`)
+	writeBundleFile(t, dir, "30-missing.yaml", `{{ .Missing }}`)
+	c, client := newBundleTestContainer(t, dir)
+
+	results := c.applyBundle(context.Background(), BundleVars{Namespace: "tenama-test-1", Creator: "jane"})
+
+	want := []models.BundleObjectStatus{
+		{Source: "10-settings.yaml", Kind: "ConfigMap", Name: "settings", Status: bundleObjectCreated},
+		{Source: "10-settings.yaml", Kind: "Widget", Name: "unknown", Status: bundleObjectFailed},
+		{Source: "20-cluster.yaml", Kind: "ClusterRole", Name: "escalate", Status: bundleObjectFailed},
+		{Source: "30-missing.yaml", Status: bundleObjectFailed},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("Expected %d results, got %+v", len(want), results)
+	}
+	for i, w := range want {
+		got := results[i]
+		if got.Source != w.Source || got.Kind != w.Kind || got.Name != w.Name || got.Status != w.Status {
+			t.Errorf("Result %d: expected %+v, got %+v", i, w, got)
+		}
+		if (got.Status == bundleObjectFailed) != (got.Error != "") {
+			t.Errorf("Result %d: expected an error exactly for failed objects, got %q", i, got.Error)
+		}
+	}
+
+	cm, err := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}).
+		Namespace("tenama-test-1").Get(context.Background(), "settings", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected created config map, got %v", err)
+	}
+	data, _, _ := unstructured.NestedStringMap(cm.Object, "data")
+	if data["namespace"] != "tenama-test-1" || data["creator"] != "jane" {
+		t.Errorf("Expected rendered variables, got %v", data)
+	}
+}
+
+func TestNewContainerBundle(t *testing.T) {
+	dir := t.TempDir()
+	writeBundleFile(t, dir, "broken.yaml", "{{ .Broken")
+
+	cfg := &models.Config{}
+	cfg.Namespace.Bundle.Directory = dir
+	if _, err := NewContainer(nil, cfg); err == nil {
+		t.Error("Expected error for invalid bundle")
+	}
+
+	cfg = &models.Config{}
+	cfg.Namespace.Bundle.Directory = t.TempDir()
+	cfg.Namespace.Bundle.ConfigMap = &models.ConfigMapRef{Namespace: "tenama-system", Name: "bundle"}
+	if _, err := NewContainer(nil, cfg); err == nil {
+		t.Error("Expected error for directory and config map")
+	}
+}
+
+// TestBundleVarsInfix tests that the infix of a request cannot add fields to the manifests
+func TestBundleVarsInfix(t *testing.T) {
+	c := &Container{config: &models.Config{}}
+	vars := c.bundleVars(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenama-ci-1"}}, &models.Namespace{Infix: "CI\nowner: mallory"})
+	if vars.Infix != "ci-owner-mallory" {
+		t.Errorf("Expected normalized infix, got %q", vars.Infix)
+	}
+}
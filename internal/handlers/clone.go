@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rootCAConfigMap is published into every namespace by the cluster
+const rootCAConfigMap = "kube-root-ca.crt"
+
+// clusterAssignedAnnotationPrefixes are set by controllers and must not be cloned
+var clusterAssignedAnnotationPrefixes = []string{
+	"deployment.kubernetes.io/",
+	"pv.kubernetes.io/",
+	"volume.kubernetes.io/",
+	"volume.beta.kubernetes.io/",
+}
+
+// cloneSource is a namespace whose objects are copied into a new namespace
+type cloneSource struct {
+	namespace              string
+	persistentVolumeClaims bool
+}
+
+// CloneNamespace - Creates a new namespace like an existing one
+func (c *Container) CloneNamespace(ctx echo.Context) error {
+	namespace := strings.Trim(ctx.Param("namespace"), "/")
+
+	if !strings.HasPrefix(namespace, c.config.Namespace.Prefix) {
+		slog.Info("Namespace does not start with prefix", "namespace", namespace, "prefix", c.config.Namespace.Prefix)
+		return c.sendErrorResponse(ctx, namespace, "Namespace does not start with prefix "+c.config.Namespace.Prefix, http.StatusBadRequest)
+	}
+
+	async, err := asyncRequested(ctx)
+	if err != nil {
+		return c.sendErrorResponse(ctx, namespace, "Invalid value for async: "+ctx.QueryParam("async"), http.StatusBadRequest)
+	}
+
+	req := models.NamespaceClone{}
+	if err := ctx.Bind(&req); err != nil {
+		slog.Error("Error parsing clone request", "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Error parsing clone request", http.StatusBadRequest)
+	}
+
+	source, err := c.clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return c.sendErrorResponse(ctx, namespace, "Namespace not found", http.StatusNotFound)
+		}
+		slog.Error("Error getting namespace", "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Error getting namespace", http.StatusInternalServerError)
+	}
+
+	if source.Labels["created-by"] != "tenama" {
+		slog.Warn("Namespace is not managed by tenama", "namespace", namespace)
+		return c.sendErrorResponse(ctx, namespace, "Namespace not found", http.StatusNotFound)
+	}
+
+	// the clone receives the Secrets and ConfigMaps of the source
+	allowed, err := c.isNamespaceUser(ctx, source)
+	if err != nil {
+		slog.Error("Error getting users of namespace", "namespace", namespace, "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Error getting users of namespace", http.StatusInternalServerError)
+	}
+	if !allowed {
+		slog.Warn("Clone of foreign namespace rejected", "namespace", namespace, "user", authenticatedUser(ctx))
+		return c.sendErrorResponse(ctx, namespace, "Only the creator and users of a namespace may clone it", http.StatusForbidden)
+	}
+
+	users, err := c.namespaceUsers(context.TODO(), namespace)
+	if err != nil {
+		slog.Error("Error getting users of namespace", "namespace", namespace, "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Error getting users of namespace", http.StatusInternalServerError)
+	}
+
+	slog.Info("Cloning namespace", "namespace", namespace, "user", authenticatedUser(ctx))
+	ns := cloneRequest(source, c.config.Namespace.Prefix, users, req)
+	return c.createNamespaceFromRequest(ctx, ns, async, &cloneSource{namespace: namespace, persistentVolumeClaims: req.PersistentVolumeClaims})
+}
+
+// namespaceUsers returns the subjects bound to the namespace by tenama. Subjects of the
+// default rolebinding have no role, so that they get the role of the template.
+func (c *Container) namespaceUsers(ctx context.Context, namespace string) ([]models.NamespaceUser, error) {
+	list, err := c.clientset.RbacV1().RoleBindings(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rolebindings: %w", err)
+	}
+	var users []models.NamespaceUser
+	for _, rb := range list.Items {
+		if !strings.HasPrefix(rb.Name, namespace+"troubleshooters") {
+			continue
+		}
+		role := ""
+		if rb.Name != namespace+"troubleshooters" {
+			role = rb.RoleRef.Name
+		}
+		for _, subject := range rb.Subjects {
+			// the service account of the namespace itself is created anew
+			if subject.Kind == rbacv1.ServiceAccountKind && subject.Namespace == "" {
+				continue
+			}
+			users = append(users, models.NamespaceUser{Name: subject.Name, Kind: subject.Kind, Namespace: subject.Namespace, Role: role})
+		}
+	}
+	return users, nil
+}
+
+// cloneRequest builds the request for a namespace with the template, users, resources,
+// pod security levels, hibernation schedule and lifetime of source. Fields of req win.
+func cloneRequest(source *v1.Namespace, prefix string, users []models.NamespaceUser, req models.NamespaceClone) models.Namespace {
+	ns := models.Namespace{
+		Infix:    req.Infix,
+		Suffix:   req.Suffix,
+		Duration: req.Duration,
+		Users:    users,
+		Template: source.Labels[templateLabel],
+	}
+	if ns.Infix == "" {
+		ns.Infix = cloneInfix(source.Name, prefix)
+	}
+	if ns.Duration == "" {
+		ns.Duration = source.Labels["tenama/namespace-duration"]
+	}
+
+	resources := models.ResourceRequest{
+		CPU:     source.Labels["tenama/resource-cpu"],
+		Memory:  source.Labels["tenama/resource-memory"],
+		Storage: source.Labels["tenama/resource-storage"],
+	}
+	if resources != (models.ResourceRequest{}) {
+		ns.Resources = &resources
+	}
+
+	podSecurity := models.PodSecurity{
+		Enforce: source.Labels["pod-security.kubernetes.io/enforce"],
+		Warn:    source.Labels["pod-security.kubernetes.io/warn"],
+		Audit:   source.Labels["pod-security.kubernetes.io/audit"],
+	}
+	if podSecurity != (models.PodSecurity{}) {
+		ns.PodSecurity = &podSecurity
+	}
+
+	if hibernate, ok := source.Annotations[hibernateScheduleAnnotation]; ok {
+		ns.Hibernation = &models.Hibernation{
+			Hibernate: hibernate,
+			WakeUp:    source.Annotations[wakeUpScheduleAnnotation],
+			TimeZone:  source.Annotations[hibernationTimeZoneAnnotation],
+		}
+	}
+	return ns
+}
+
+// cloneInfix returns the infix of a namespace named prefix-infix-suffix
+func cloneInfix(name string, prefix string) string {
+	infix := strings.TrimPrefix(name, prefix)
+	if i := strings.LastIndex(infix, separationString); i > 0 {
+		infix = infix[:i]
+	}
+	return strings.Trim(infix, separationString)
+}
+
+// cloneMeta returns the metadata of a cloned object, without the fields and
+// annotations assigned by the cluster
+func cloneMeta(namespace string, source metav1.ObjectMeta) metav1.ObjectMeta {
+	meta := copyMeta(namespace, source)
+	for key := range meta.Annotations {
+		for _, prefix := range clusterAssignedAnnotationPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(meta.Annotations, key)
+			}
+		}
+	}
+	return meta
+}
+
+// cloneObjects copies the ConfigMaps, Secrets, Services, Deployments and optionally the
+// PersistentVolumeClaims of the source into the namespace and returns them as kind/name.
+// Objects owned by other objects are left to their controllers, objects that already
+// exist in the namespace are kept.
+func (c *Container) cloneObjects(ctx context.Context, src *cloneSource, namespace string) ([]string, error) {
+	var cloned []string
+	created := func(kind, name string, err error) error {
+		if apierrors.IsAlreadyExists(err) {
+			slog.Debug("Object already exists in clone", "namespace", namespace, "kind", kind, "name", name)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to clone %s %s: %w", kind, name, err)
+		}
+		cloned = append(cloned, kind+"/"+name)
+		return nil
+	}
+	core := c.clientset.CoreV1()
+
+	configMaps, err := core.ConfigMaps(src.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config maps: %w", err)
+	}
+	for _, cm := range configMaps.Items {
+		if len(cm.OwnerReferences) > 0 || cm.Name == rootCAConfigMap {
+			continue
+		}
+		_, err := core.ConfigMaps(namespace).Create(ctx, &v1.ConfigMap{
+			ObjectMeta: cloneMeta(namespace, cm.ObjectMeta),
+			Data:       cm.Data,
+			BinaryData: cm.BinaryData,
+			Immutable:  cm.Immutable,
+		}, metav1.CreateOptions{})
+		if err := created("ConfigMap", cm.Name, err); err != nil {
+			return nil, err
+		}
+	}
+
+	secrets, err := core.Secrets(src.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	for _, secret := range secrets.Items {
+		if len(secret.OwnerReferences) > 0 || secret.Type == v1.SecretTypeServiceAccountToken {
+			continue
+		}
+		_, err := core.Secrets(namespace).Create(ctx, &v1.Secret{
+			ObjectMeta: cloneMeta(namespace, secret.ObjectMeta),
+			Type:       secret.Type,
+			Data:       secret.Data,
+			Immutable:  secret.Immutable,
+		}, metav1.CreateOptions{})
+		if err := created("Secret", secret.Name, err); err != nil {
+			return nil, err
+		}
+	}
+
+	if src.persistentVolumeClaims {
+		claims, err := core.PersistentVolumeClaims(src.namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list persistent volume claims: %w", err)
+		}
+		for _, pvc := range claims.Items {
+			if len(pvc.OwnerReferences) > 0 {
+				continue
+			}
+			// only the definition is cloned, the claim is bound to a new empty volume
+			_, err := core.PersistentVolumeClaims(namespace).Create(ctx, &v1.PersistentVolumeClaim{
+				ObjectMeta: cloneMeta(namespace, pvc.ObjectMeta),
+				Spec: v1.PersistentVolumeClaimSpec{
+					AccessModes:      pvc.Spec.AccessModes,
+					Resources:        pvc.Spec.Resources,
+					StorageClassName: pvc.Spec.StorageClassName,
+					VolumeMode:       pvc.Spec.VolumeMode,
+				},
+			}, metav1.CreateOptions{})
+			if err := created("PersistentVolumeClaim", pvc.Name, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	services, err := core.Services(src.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	for _, svc := range services.Items {
+		if len(svc.OwnerReferences) > 0 {
+			continue
+		}
+		_, err := core.Services(namespace).Create(ctx, &v1.Service{
+			ObjectMeta: cloneMeta(namespace, svc.ObjectMeta),
+			Spec:       cloneServiceSpec(svc.Spec),
+		}, metav1.CreateOptions{})
+		if err := created("Service", svc.Name, err); err != nil {
+			return nil, err
+		}
+	}
+
+	deployments, err := c.clientset.AppsV1().Deployments(src.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, d := range deployments.Items {
+		if len(d.OwnerReferences) > 0 {
+			continue
+		}
+		deployment := &appsv1.Deployment{
+			ObjectMeta: cloneMeta(namespace, d.ObjectMeta),
+			Spec:       *d.Spec.DeepCopy(),
+		}
+		// a hibernated source starts the clone with its original replica count
+		if replicas, ok, err := restoreReplicas(&deployment.ObjectMeta); err == nil && ok {
+			deployment.Spec.Replicas = replicas
+		}
+		_, err := c.clientset.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
+		if err := created("Deployment", d.Name, err); err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info("Cloned objects", "namespace", namespace, "source", src.namespace, "objects", len(cloned))
+	return cloned, nil
+}
+
+// cloneServiceSpec returns the spec of a service without the IPs and node ports
+// allocated by the cluster
+func cloneServiceSpec(spec v1.ServiceSpec) v1.ServiceSpec {
+	cp := *spec.DeepCopy()
+	// headless services stay headless
+	if cp.ClusterIP != v1.ClusterIPNone {
+		cp.ClusterIP = ""
+	}
+	cp.ClusterIPs = nil
+	cp.HealthCheckNodePort = 0
+	for i := range cp.Ports {
+		cp.Ports[i].NodePort = 0
+	}
+	return cp
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCloneInfix(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"tenama-bug-1234-abcde", "bug-1234"},
+		{"tenama-test-abcde", "test"},
+		{"tenama-test", "test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cloneInfix(tt.name, "tenama"); got != tt.want {
+				t.Errorf("Expected infix %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCloneRequest(t *testing.T) {
+	source := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "tenama-test-abcde",
+			Labels: map[string]string{
+				templateLabel:                        "small-ci",
+				"tenama/namespace-duration":          "24h0m0s",
+				"tenama/resource-cpu":                "2",
+				"pod-security.kubernetes.io/enforce": "restricted",
+			},
+			Annotations: map[string]string{
+				hibernateScheduleAnnotation: "0 19 * * 1-5",
+				wakeUpScheduleAnnotation:    "0 7 * * 1-5",
+			},
+		},
+	}
+
+	got := cloneRequest(source, "tenama", []models.NamespaceUser{{Name: "jane"}}, models.NamespaceClone{Duration: "2h"})
+	want := models.Namespace{
+		Infix:       "test",
+		Duration:    "2h",
+		Users:       []models.NamespaceUser{{Name: "jane"}},
+		Template:    "small-ci",
+		Resources:   &models.ResourceRequest{CPU: "2"},
+		PodSecurity: &models.PodSecurity{Enforce: "restricted"},
+		Hibernation: &models.Hibernation{Hibernate: "0 19 * * 1-5", WakeUp: "0 7 * * 1-5"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if got := cloneRequest(source, "tenama", nil, models.NamespaceClone{}); got.Duration != "24h0m0s" {
+		t.Errorf("Expected the lifetime of the source, got %q", got.Duration)
+	}
+}
+
+func TestNamespaceUsers(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenama-test-abcdetroubleshooters", Namespace: "tenama-test-abcde"},
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.UserKind, Name: "jane"},
+				{Kind: rbacv1.ServiceAccountKind, Name: "tenama-sa"},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenama-test-abcdetroubleshooters-view", Namespace: "tenama-test-abcde"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.GroupKind, Name: "qa"},
+				{Kind: rbacv1.ServiceAccountKind, Name: "deployer", Namespace: "ci"},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenama-admin", Namespace: "tenama-test-abcde"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "tenama", Namespace: "tenama-system"}},
+		},
+	)
+	c := &Container{clientset: clientset, config: &models.Config{}}
+
+	users, err := c.namespaceUsers(context.Background(), "tenama-test-abcde")
+	want := []models.NamespaceUser{
+		{Name: "jane", Kind: rbacv1.UserKind},
+		{Name: "qa", Kind: rbacv1.GroupKind, Role: "view"},
+		{Name: "deployer", Kind: rbacv1.ServiceAccountKind, Namespace: "ci", Role: "view"},
+	}
+	if err != nil || !reflect.DeepEqual(users, want) {
+		t.Errorf("Expected %+v, got %+v (%v)", want, users, err)
+	}
+	if users, err := c.namespaceUsers(context.Background(), "tenama-other-abcde"); err != nil || users != nil {
+		t.Errorf("Expected no users without rolebinding, got %v (%v)", users, err)
+	}
+}
+
+func TestCloneObjects(t *testing.T) {
+	replicas := int32(0)
+	clientset := fake.NewSimpleClientset(
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "tenama-test-abcde", ResourceVersion: "7"}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: rootCAConfigMap, Namespace: "tenama-test-abcde"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "tenama-test-abcde"}, Type: v1.SecretTypeOpaque},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "tenama-sa-token", Namespace: "tenama-test-abcde"}, Type: v1.SecretTypeServiceAccountToken},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:            "generated",
+			Namespace:       "tenama-test-abcde",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Certificate", Name: "web"}},
+		}},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenama-test-abcde"},
+			Spec: v1.ServiceSpec{
+				Type:       v1.ServiceTypeNodePort,
+				ClusterIP:  "10.0.0.1",
+				ClusterIPs: []string{"10.0.0.1"},
+				Ports:      []v1.ServicePort{{Port: 80, NodePort: 30080}},
+			},
+		},
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "data",
+				Namespace:   "tenama-test-abcde",
+				Annotations: map[string]string{"pv.kubernetes.io/bind-completed": "yes"},
+			},
+			Spec: v1.PersistentVolumeClaimSpec{VolumeName: "pvc-1234", AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web",
+				Namespace: "tenama-test-abcde",
+				Annotations: map[string]string{
+					"deployment.kubernetes.io/revision": "3",
+					originalReplicasAnnotation:          "2",
+				},
+			},
+			Spec: appsv1.DeploymentSpec{Replicas: &replicas},
+		},
+	)
+	c := &Container{clientset: clientset, config: &models.Config{}}
+	ctx := context.Background()
+
+	cloned, err := c.cloneObjects(ctx, &cloneSource{namespace: "tenama-test-abcde", persistentVolumeClaims: true}, "tenama-test-fghij")
+	if err != nil {
+		t.Fatalf("cloneObjects returned error: %v", err)
+	}
+	sort.Strings(cloned)
+	want := []string{"ConfigMap/settings", "Deployment/web", "PersistentVolumeClaim/data", "Secret/credentials", "Service/web"}
+	if !reflect.DeepEqual(cloned, want) {
+		t.Errorf("Expected %v, got %v", want, cloned)
+	}
+
+	svc, err := clientset.CoreV1().Services("tenama-test-fghij").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected cloned service, got %v", err)
+	}
+	if svc.Spec.ClusterIP != "" || svc.Spec.ClusterIPs != nil || svc.Spec.Ports[0].NodePort != 0 {
+		t.Errorf("Expected cluster-assigned fields to be stripped, got %+v", svc.Spec)
+	}
+
+	pvc, err := clientset.CoreV1().PersistentVolumeClaims("tenama-test-fghij").Get(ctx, "data", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected cloned claim, got %v", err)
+	}
+	if pvc.Spec.VolumeName != "" || pvc.Annotations["pv.kubernetes.io/bind-completed"] != "" || len(pvc.Spec.AccessModes) != 1 {
+		t.Errorf("Expected the definition without the binding, got %+v", pvc)
+	}
+
+	deployment, err := clientset.AppsV1().Deployments("tenama-test-fghij").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected cloned deployment, got %v", err)
+	}
+	if *deployment.Spec.Replicas != 2 || deployment.Annotations[originalReplicasAnnotation] != "" || deployment.Annotations["deployment.kubernetes.io/revision"] != "" {
+		t.Errorf("Expected original replicas without controller annotations, got %+v", deployment.ObjectMeta)
+	}
+
+	// cloning again keeps the existing objects
+	if cloned, err := c.cloneObjects(ctx, &cloneSource{namespace: "tenama-test-abcde"}, "tenama-test-fghij"); err != nil || len(cloned) != 0 {
+		t.Errorf("Expected existing objects to be kept, got %v (%v)", cloned, err)
+	}
+}
+
+// TestCloneNamespaceNotFound tests that only namespaces managed by tenama can be cloned
+func TestCloneNamespaceNotFound(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenama-foreign"}})
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	c := &Container{clientset: clientset, config: cfg}
+
+	for _, namespace := range []string{"tenama-missing", "tenama-foreign"} {
+		req := httptest.NewRequest(http.MethodPost, "/namespace/"+namespace+"/clone", nil)
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(req, rec)
+		ctx.SetParamNames("namespace")
+		ctx.SetParamValues(namespace)
+
+		if err := c.CloneNamespace(ctx); err != nil {
+			t.Fatalf("CloneNamespace returned error: %v", err)
+		}
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", namespace, rec.Code)
+		}
+	}
+}
+
+// TestCloneNamespaceForbidden tests that only the creator and users of a namespace can clone it
+func TestCloneNamespaceForbidden(t *testing.T) {
+	ns, rb, view := ownedNamespace()
+	clientset := fake.NewSimpleClientset(ns, rb, view)
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	c := &Container{clientset: clientset, config: cfg}
+
+	req := httptest.NewRequest(http.MethodPost, "/namespace/"+ns.Name+"/clone", nil)
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(req, rec)
+	ctx.SetParamNames("namespace")
+	ctx.SetParamValues(ns.Name)
+	ctx.Set(authUserKey, "mallory")
+
+	if err := c.CloneNamespace(ctx); err != nil {
+		t.Fatalf("CloneNamespace returned error: %v", err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+	list, _ := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if len(list.Items) != 1 {
+		t.Errorf("Expected no clone to be created, got %d namespaces", len(list.Items))
+	}
+}
@@ -1,23 +1,84 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/Payback159/tenama/internal/models"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
 // Container will hold all dependencies for your application.
 type Container struct {
-	clientset *kubernetes.Clientset
-	config    *models.Config
-	watcher   *NamespaceWatcher
+	clientset   kubernetes.Interface
+	config      *models.Config
+	watcher     *NamespaceWatcher
+	hooks       *HookRunner
+	operations  *OperationStore
+	idempotency *IdempotencyStore
+	// clients and manifests of the bundle that is created in every namespace
+	dynamicClient dynamic.Interface
+	restMapper    meta.ResettableRESTMapper
+	bundle        []bundleFile
+	// endpoint and certificate authority of the API server that are written into the kubeconfigs
+	clusterServer string
+	clusterCA     []byte
 }
 
 // NewContainer returns an empty or an initialized container for your handlers.
-func NewContainer(clientset *kubernetes.Clientset, cfg *models.Config) (*Container, error) {
+func NewContainer(clientset kubernetes.Interface, cfg *models.Config) (*Container, error) {
+	if err := validateDurationPolicies(cfg); err != nil {
+		return nil, fmt.Errorf("invalid duration policy: %w", err)
+	}
+	if err := validateNameTemplate(cfg.Namespace.NameTemplate, cfg.Namespace.Prefix); err != nil {
+		return nil, fmt.Errorf("invalid name template: %w", err)
+	}
+	if err := validateMaxResources(cfg.Namespace.MaxResources); err != nil {
+		return nil, fmt.Errorf("invalid max resources: %w", err)
+	}
+	if cfg.Namespace.LimitRange.Enabled {
+		if err := validateLimitRange(&cfg.Namespace.LimitRange.LimitRange); err != nil {
+			return nil, fmt.Errorf("invalid limit range: %w", err)
+		}
+	}
+	if cfg.Namespace.NetworkPolicy.Enabled {
+		if err := validateNetworkPolicy(cfg.Namespace.NetworkPolicy); err != nil {
+			return nil, fmt.Errorf("invalid network policy: %w", err)
+		}
+	}
+	if err := validateCopyConfig(cfg.Namespace.Copy); err != nil {
+		return nil, fmt.Errorf("invalid copy config: %w", err)
+	}
+	if err := validatePodSecurityConfig(cfg.Namespace.PodSecurity); err != nil {
+		return nil, fmt.Errorf("invalid pod security config: %w", err)
+	}
+	if err := validateTemplates(cfg); err != nil {
+		return nil, fmt.Errorf("invalid namespace template: %w", err)
+	}
+	operationTTL, err := durationOrDefault(cfg.Operations.TTL, defaultOperationTTL)
+	if err != nil || operationTTL <= 0 {
+		return nil, fmt.Errorf("invalid operation ttl %q", cfg.Operations.TTL)
+	}
+	idempotencyTTL, err := durationOrDefault(cfg.Idempotency.TTL, defaultIdempotencyTTL)
+	if err != nil || idempotencyTTL <= 0 {
+		return nil, fmt.Errorf("invalid idempotency ttl %q", cfg.Idempotency.TTL)
+	}
 	c := Container{
-		clientset: clientset,
-		config:    cfg,
-		watcher:   nil, // Will be set later via SetWatcher
+		clientset:   clientset,
+		config:      cfg,
+		watcher:     nil, // Will be set later via SetWatcher
+		operations:  NewOperationStore(operationTTL),
+		idempotency: NewIdempotencyStore(idempotencyTTL),
+	}
+	if cfg.Namespace.Bundle.Directory != "" && cfg.Namespace.Bundle.ConfigMap != nil {
+		return nil, errors.New("bundle directory and config map are mutually exclusive")
+	}
+	if cfg.Namespace.Bundle.Directory != "" {
+		if c.bundle, err = loadBundleDirectory(cfg.Namespace.Bundle.Directory); err != nil {
+			return nil, fmt.Errorf("invalid manifest bundle: %w", err)
+		}
 	}
 	return &c, nil
 }
@@ -26,3 +87,14 @@ func NewContainer(clientset *kubernetes.Clientset, cfg *models.Config) (*Contain
 func (c *Container) SetWatcher(watcher *NamespaceWatcher) {
 	c.watcher = watcher
 }
+
+// SetCluster sets the endpoint and the certificate authority data of the API server for the kubeconfigs
+func (c *Container) SetCluster(server string, caData []byte) {
+	c.clusterServer = server
+	c.clusterCA = caData
+}
+
+// SetPreDeleteHooks sets the hooks that run before a namespace is deleted through the API
+func (c *Container) SetPreDeleteHooks(hooks *HookRunner) {
+	c.hooks = hooks
+}
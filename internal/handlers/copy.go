@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// copiedFromAnnotation records the origin of a copied object
+const copiedFromAnnotation = "tenama/copied-from"
+
+// validateCopyConfig checks that every source selects its objects either by name or by labels
+func validateCopyConfig(cfg models.CopyConfig) error {
+	sources := append([]models.CopySource{}, cfg.Secrets...)
+	sources = append(sources, cfg.ConfigMaps...)
+	for _, src := range sources {
+		if src.Namespace == "" {
+			return errors.New("source without namespace")
+		}
+		if (src.Name == "") == (len(src.Selector) == 0) {
+			return fmt.Errorf("source in namespace %s: either name or selector is required", src.Namespace)
+		}
+	}
+	return nil
+}
+
+// copyMeta returns the metadata of a copy, without the fields assigned by the cluster
+func copyMeta(namespace string, source metav1.ObjectMeta) metav1.ObjectMeta {
+	annotations := map[string]string{}
+	for key, value := range source.Annotations {
+		if key != v1.LastAppliedConfigAnnotation {
+			annotations[key] = value
+		}
+	}
+	annotations[copiedFromAnnotation] = source.Namespace + "/" + source.Name
+	return metav1.ObjectMeta{
+		Name:        source.Name,
+		Namespace:   namespace,
+		Labels:      source.Labels,
+		Annotations: annotations,
+	}
+}
+
+// sourceSecrets returns the secrets selected by a copy source
+func (c *Container) sourceSecrets(ctx context.Context, src models.CopySource) ([]v1.Secret, error) {
+	secrets := c.clientset.CoreV1().Secrets(src.Namespace)
+	if src.Name != "" {
+		secret, err := secrets.Get(ctx, src.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secret %s/%s: %w", src.Namespace, src.Name, err)
+		}
+		return []v1.Secret{*secret}, nil
+	}
+	list, err := secrets.List(ctx, metav1.ListOptions{LabelSelector: labels.SelectorFromSet(src.Selector).String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets in %s: %w", src.Namespace, err)
+	}
+	return list.Items, nil
+}
+
+// sourceConfigMaps returns the config maps selected by a copy source
+func (c *Container) sourceConfigMaps(ctx context.Context, src models.CopySource) ([]v1.ConfigMap, error) {
+	configMaps := c.clientset.CoreV1().ConfigMaps(src.Namespace)
+	if src.Name != "" {
+		cm, err := configMaps.Get(ctx, src.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get config map %s/%s: %w", src.Namespace, src.Name, err)
+		}
+		return []v1.ConfigMap{*cm}, nil
+	}
+	list, err := configMaps.List(ctx, metav1.ListOptions{LabelSelector: labels.SelectorFromSet(src.Selector).String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config maps in %s: %w", src.Namespace, err)
+	}
+	return list.Items, nil
+}
+
+// copyObjects copies the configured Secrets and ConfigMaps into the namespace and returns
+// the names of the copied image pull secrets. Service account tokens are never copied.
+func (c *Container) copyObjects(ctx context.Context, namespace string) ([]string, error) {
+	cfg := c.config.Namespace.Copy
+	var pullSecrets []string
+
+	for _, src := range cfg.Secrets {
+		secrets, err := c.sourceSecrets(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range secrets {
+			if secret.Type == v1.SecretTypeServiceAccountToken {
+				slog.Warn("Not copying service account token", "secret", secret.Name, "namespace", secret.Namespace)
+				continue
+			}
+			cp := &v1.Secret{
+				ObjectMeta: copyMeta(namespace, secret.ObjectMeta),
+				Type:       secret.Type,
+				Data:       secret.Data,
+			}
+			if _, err := c.clientset.CoreV1().Secrets(namespace).Create(ctx, cp, metav1.CreateOptions{}); err != nil {
+				return nil, fmt.Errorf("failed to copy secret %s/%s: %w", secret.Namespace, secret.Name, err)
+			}
+			if (secret.Type == v1.SecretTypeDockerConfigJson || secret.Type == v1.SecretTypeDockercfg) && !slices.Contains(pullSecrets, secret.Name) {
+				pullSecrets = append(pullSecrets, secret.Name)
+			}
+		}
+	}
+
+	for _, src := range cfg.ConfigMaps {
+		configMaps, err := c.sourceConfigMaps(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		for _, cm := range configMaps {
+			cp := &v1.ConfigMap{
+				ObjectMeta: copyMeta(namespace, cm.ObjectMeta),
+				Data:       cm.Data,
+				BinaryData: cm.BinaryData,
+			}
+			if _, err := c.clientset.CoreV1().ConfigMaps(namespace).Create(ctx, cp, metav1.CreateOptions{}); err != nil {
+				return nil, fmt.Errorf("failed to copy config map %s/%s: %w", cm.Namespace, cm.Name, err)
+			}
+		}
+	}
+	return pullSecrets, nil
+}
+
+// localObjectReferences converts secret names into image pull secret references
+func localObjectReferences(names []string) []v1.LocalObjectReference {
+	var refs []v1.LocalObjectReference
+	for _, name := range names {
+		refs = append(refs, v1.LocalObjectReference{Name: name})
+	}
+	return refs
+}
+
+// addImagePullSecrets adds the secrets to a ServiceAccount. The default ServiceAccount
+// is created by a controller and may not exist yet, so it is created if missing.
+func (c *Container) addImagePullSecrets(ctx context.Context, namespace, serviceAccount string, names []string) error {
+	serviceAccounts := c.clientset.CoreV1().ServiceAccounts(namespace)
+	patch, err := json.Marshal(map[string]any{"imagePullSecrets": localObjectReferences(names)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal image pull secrets: %w", err)
+	}
+
+	_, err = serviceAccounts.Patch(ctx, serviceAccount, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	if !apierrors.IsNotFound(err) {
+		if err != nil {
+			return fmt.Errorf("failed to add image pull secrets to service account %s: %w", serviceAccount, err)
+		}
+		return nil
+	}
+
+	sa := &v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: serviceAccount, Namespace: namespace},
+		ImagePullSecrets: localObjectReferences(names),
+	}
+	_, err = serviceAccounts.Create(ctx, sa, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		// created by the controller in the meantime
+		_, err = serviceAccounts.Patch(ctx, serviceAccount, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add image pull secrets to service account %s: %w", serviceAccount, err)
+	}
+	return nil
+}
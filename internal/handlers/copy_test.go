@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestValidateCopyConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     models.CopySource
+		wantErr bool
+	}{
+		{"by name", models.CopySource{Namespace: "shared", Name: "registry"}, false},
+		{"by selector", models.CopySource{Namespace: "shared", Selector: map[string]string{"tenama/copy": "true"}}, false},
+		{"without namespace", models.CopySource{Name: "registry"}, true},
+		{"name and selector", models.CopySource{Namespace: "shared", Name: "registry", Selector: map[string]string{"a": "b"}}, true},
+		{"neither", models.CopySource{Namespace: "shared"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCopyConfig(models.CopyConfig{ConfigMaps: []models.CopySource{tt.src}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCopyObjects(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "registry",
+				Namespace:       "shared",
+				ResourceVersion: "42",
+				UID:             "abc",
+				Annotations:     map[string]string{v1.LastAppliedConfigAnnotation: "{}"},
+			},
+			Type: v1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{v1.DockerConfigJsonKey: []byte("{}")},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "shared", Labels: map[string]string{"tenama/copy": "true"}},
+			Type:       v1.SecretTypeServiceAccountToken,
+		},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "ca-bundle", Namespace: "shared", Labels: map[string]string{"tenama/copy": "true"}},
+			Data:       map[string]string{"ca.crt": "cert"},
+		},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "shared"},
+		},
+	)
+	cfg := &models.Config{}
+	cfg.Namespace.Copy = models.CopyConfig{
+		Secrets: []models.CopySource{
+			{Namespace: "shared", Name: "registry"},
+			{Namespace: "shared", Selector: map[string]string{"tenama/copy": "true"}},
+		},
+		ConfigMaps: []models.CopySource{{Namespace: "shared", Selector: map[string]string{"tenama/copy": "true"}}},
+	}
+	c := &Container{clientset: clientset, config: cfg}
+
+	pullSecrets, err := c.copyObjects(context.Background(), "tenama-test-1")
+	if err != nil {
+		t.Fatalf("copyObjects returned error: %v", err)
+	}
+	if !reflect.DeepEqual(pullSecrets, []string{"registry"}) {
+		t.Errorf("Expected registry as pull secret, got %v", pullSecrets)
+	}
+
+	secret, err := clientset.CoreV1().Secrets("tenama-test-1").Get(context.Background(), "registry", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected copied secret, got %v", err)
+	}
+	if secret.UID != "" || secret.Annotations[v1.LastAppliedConfigAnnotation] != "" || secret.Annotations[copiedFromAnnotation] != "shared/registry" {
+		t.Errorf("Expected stripped metadata with origin, got %+v", secret.ObjectMeta)
+	}
+	if _, err := clientset.CoreV1().Secrets("tenama-test-1").Get(context.Background(), "token", metav1.GetOptions{}); err == nil {
+		t.Error("Expected service account token not to be copied")
+	}
+	if _, err := clientset.CoreV1().ConfigMaps("tenama-test-1").Get(context.Background(), "ca-bundle", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected copied config map, got %v", err)
+	}
+	if _, err := clientset.CoreV1().ConfigMaps("tenama-test-1").Get(context.Background(), "unrelated", metav1.GetOptions{}); err == nil {
+		t.Error("Expected unselected config map not to be copied")
+	}
+}
+
+func TestAddImagePullSecrets(t *testing.T) {
+	existing := &v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "tenama-test-1"}}
+	for _, tt := range []struct {
+		name    string
+		objects []v1.ServiceAccount
+	}{
+		{"existing service account", []v1.ServiceAccount{*existing}},
+		{"missing service account", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			for i := range tt.objects {
+				_, _ = clientset.CoreV1().ServiceAccounts("tenama-test-1").Create(context.Background(), &tt.objects[i], metav1.CreateOptions{})
+			}
+			c := &Container{clientset: clientset, config: &models.Config{}}
+
+			if err := c.addImagePullSecrets(context.Background(), "tenama-test-1", "default", []string{"registry"}); err != nil {
+				t.Fatalf("addImagePullSecrets returned error: %v", err)
+			}
+			sa, err := clientset.CoreV1().ServiceAccounts("tenama-test-1").Get(context.Background(), "default", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Expected service account, got %v", err)
+			}
+			if len(sa.ImagePullSecrets) != 1 || sa.ImagePullSecrets[0].Name != "registry" {
+				t.Errorf("Expected registry pull secret, got %v", sa.ImagePullSecrets)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{"every minute", "* * * * *", false},
+		{"weekday evenings", "0 19 * * 1-5", false},
+		{"lists and steps", "*/15 8,12,18 1-15/2 * 0,7", false},
+		{"too few fields", "0 19 * *", true},
+		{"minute out of range", "60 * * * *", true},
+		{"invalid range", "0 19 * * 5-1", true},
+		{"invalid step", "*/0 * * * *", true},
+		{"not a number", "0 evening * * *", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCron(tt.expr, time.UTC)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		expr     string
+		location *time.Location
+		after    time.Time
+		want     time.Time
+	}{
+		{
+			name:     "same day",
+			expr:     "0 19 * * 1-5",
+			location: time.UTC,
+			after:    time.Date(2025, 1, 6, 10, 30, 0, 0, time.UTC), // Monday
+			want:     time.Date(2025, 1, 6, 19, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "skips the weekend",
+			expr:     "0 7 * * 1-5",
+			location: time.UTC,
+			after:    time.Date(2025, 1, 10, 19, 0, 0, 0, time.UTC), // Friday
+			want:     time.Date(2025, 1, 13, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "strictly after",
+			expr:     "0 19 * * *",
+			location: time.UTC,
+			after:    time.Date(2025, 1, 6, 19, 0, 0, 0, time.UTC),
+			want:     time.Date(2025, 1, 7, 19, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "time zone aware",
+			expr:     "0 7 * * *",
+			location: vienna,
+			after:    time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC),
+			want:     time.Date(2025, 7, 2, 5, 0, 0, 0, time.UTC), // CEST is UTC+2
+		},
+		{
+			name:     "steps",
+			expr:     "*/20 * * * *",
+			location: time.UTC,
+			after:    time.Date(2025, 1, 6, 10, 41, 0, 0, time.UTC),
+			want:     time.Date(2025, 1, 6, 11, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, err := parseCron(tt.expr, tt.location)
+			if err != nil {
+				t.Fatalf("parseCron returned error: %v", err)
+			}
+			if got := cs.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.after, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// expiresAtAnnotation stores the absolute expiry of a namespace in RFC3339 format
+const expiresAtAnnotation = "tenama/expires-at"
+
+// durationUnits are the units that time.ParseDuration does not know about
+var durationUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// parseDuration parses a duration like time.ParseDuration, but additionally accepts
+// days and weeks, e.g. "7d", "1w2d" or "1d12h"
+func parseDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	if s == "" {
+		return 0, errors.New("invalid duration \"\"")
+	}
+
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		numberEnd := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if numberEnd <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		unitEnd := strings.IndexFunc(rest[numberEnd:], func(r rune) bool { return r >= '0' && r <= '9' })
+		if unitEnd < 0 {
+			unitEnd = len(rest) - numberEnd
+		}
+		number, unit := rest[:numberEnd], rest[numberEnd:numberEnd+unitEnd]
+		rest = rest[numberEnd+unitEnd:]
+
+		if factor, ok := durationUnits[unit]; ok {
+			value, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			total += time.Duration(value * float64(factor))
+			continue
+		}
+		d, err := time.ParseDuration(number + unit)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		total += d
+	}
+	return total, nil
+}
+
+// parseExpiry interprets value either as an absolute RFC3339 timestamp or as a
+// duration relative to now and returns the resulting expiry
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	var expiresAt time.Time
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		expiresAt = t
+	} else {
+		d, err := parseDuration(value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is neither a duration nor an RFC3339 timestamp", value)
+		}
+		expiresAt = now.Add(d)
+	}
+
+	if !expiresAt.After(now) {
+		return time.Time{}, fmt.Errorf("expiry %s is not in the future", expiresAt.UTC().Format(time.RFC3339))
+	}
+	return expiresAt, nil
+}
+
+// setExpiry stores the expiry of a namespace in the expires-at annotation and the
+// matching lifetime since its creation in the duration label
+func setExpiry(ns *v1.Namespace, created time.Time, expiresAt time.Time) {
+	if ns.Labels == nil {
+		ns.Labels = make(map[string]string)
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = make(map[string]string)
+	}
+	ns.Labels["tenama/namespace-duration"] = expiresAt.Sub(created).Round(time.Second).String()
+	ns.Annotations[expiresAtAnnotation] = expiresAt.UTC().Format(time.RFC3339)
+}
+
+// namespaceExpiration returns the point in time at which a namespace expires. The
+// expires-at annotation takes precedence, namespaces without it expire after the
+// duration label has passed since their creation.
+func namespaceExpiration(ns *v1.Namespace) (time.Time, error) {
+	if value, ok := ns.Annotations[expiresAtAnnotation]; ok {
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err == nil {
+			return expiresAt, nil
+		}
+		slog.Warn("Invalid expiry annotation, falling back to duration label", "namespace", ns.Name, "error", err)
+	}
+
+	duration, err := parseDuration(ns.Labels["tenama/namespace-duration"])
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ns.ObjectMeta.CreationTimestamp.Time.Add(duration), nil
+}
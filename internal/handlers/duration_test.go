@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90m", 90 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"1w2d", 9 * 24 * time.Hour, false},
+		{"1d12h30m", 36*time.Hour + 30*time.Minute, false},
+		{"", 0, true},
+		{"d", 0, true},
+		{"7", 0, true},
+		{"7y", 0, true},
+		{"1d-2h", 0, true},
+		{"forever", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseDuration(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDuration(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseDuration(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"relative duration", "24h", now.Add(24 * time.Hour), false},
+		{"relative days", "3d", now.Add(72 * time.Hour), false},
+		{"absolute timestamp", "2025-01-03T18:00:00Z", time.Date(2025, 1, 3, 18, 0, 0, 0, time.UTC), false},
+		{"absolute timestamp with offset", "2025-01-03T18:00:00+01:00", time.Date(2025, 1, 3, 17, 0, 0, 0, time.UTC), false},
+		{"timestamp in the past", "2024-12-31T18:00:00Z", time.Time{}, true},
+		{"zero duration", "0s", time.Time{}, true},
+		{"negative duration", "-1h", time.Time{}, true},
+		{"garbage", "next friday", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExpiry(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseExpiry(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseExpiry(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceExpiration(t *testing.T) {
+	created := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        time.Time
+		wantErr     bool
+	}{
+		{
+			name:   "label only",
+			labels: map[string]string{"tenama/namespace-duration": "24h0m0s"},
+			want:   created.Add(24 * time.Hour),
+		},
+		{
+			name:        "annotation takes precedence",
+			labels:      map[string]string{"tenama/namespace-duration": "24h0m0s"},
+			annotations: map[string]string{expiresAtAnnotation: "2025-01-03T18:00:00Z"},
+			want:        time.Date(2025, 1, 3, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "invalid annotation falls back to label",
+			labels:      map[string]string{"tenama/namespace-duration": "1d"},
+			annotations: map[string]string{expiresAtAnnotation: "friday"},
+			want:        created.Add(24 * time.Hour),
+		},
+		{
+			name:    "invalid label",
+			labels:  map[string]string{"tenama/namespace-duration": "forever"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "tenama-test",
+					CreationTimestamp: metav1.NewTime(created),
+					Labels:            tt.labels,
+					Annotations:       tt.annotations,
+				},
+			}
+			got, err := namespaceExpiration(ns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("namespaceExpiration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("namespaceExpiration() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetExpiry(t *testing.T) {
+	created := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenama-test"}}
+
+	setExpiry(ns, created, created.Add(7*24*time.Hour+30*time.Minute))
+
+	if got := ns.Labels["tenama/namespace-duration"]; got != "168h30m0s" {
+		t.Errorf("Expected duration label 168h30m0s, got %s", got)
+	}
+	if got := ns.Annotations[expiresAtAnnotation]; got != "2025-01-08T12:30:00Z" {
+		t.Errorf("Expected expires-at annotation 2025-01-08T12:30:00Z, got %s", got)
+	}
+}
@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// groupLabel holds the ID of the group a namespace was created in
+	groupLabel = "tenama/group"
+	// maxGroupSize bounds the number of namespaces created in one group
+	maxGroupSize = 10
+)
+
+// CreateNamespaceGroup - Creates several namespaces that share their lifecycle
+func (c *Container) CreateNamespaceGroup(ctx echo.Context) error {
+	req := models.NamespaceGroup{}
+	if err := ctx.Bind(&req); err != nil {
+		slog.Error("Error parsing group request", "error", err)
+		return c.sendErrorResponse(ctx, "", "Error parsing group request", http.StatusBadRequest)
+	}
+	if len(req.Namespaces) == 0 || len(req.Namespaces) > maxGroupSize {
+		return c.sendErrorResponse(ctx, "", fmt.Sprintf("A group consists of 1 to %d namespaces", maxGroupSize), http.StatusBadRequest)
+	}
+
+	namespaceList, err := getNamespaceList(c.clientset)
+	if err != nil {
+		slog.Error("Error listing namespaces", "error", err)
+		return c.sendErrorResponse(ctx, "", "Error listing namespaces", http.StatusInternalServerError)
+	}
+
+	group := string(uuid.NewUUID())
+	specs := make([]*v1.Namespace, len(req.Namespaces))
+	resources := make([]*models.ResourceRequest, len(req.Namespaces))
+	var expiresAt time.Time
+	for i := range req.Namespaces {
+		member := &req.Namespaces[i]
+		member.Duration = req.Duration
+		nsSpec, err := c.prepareNamespace(ctx, member)
+		if err != nil {
+			return c.sendRequestError(ctx, "", groupMemberError(i, err))
+		}
+		if !c.randomName(member) && existsNamespace(namespaceList, nsSpec.Name) {
+			return c.sendErrorResponse(ctx, nsSpec.Name, "Namespace already exists", http.StatusConflict)
+		}
+		// members without a group duration may have different template defaults, the earliest wins
+		memberExpiresAt, err := namespaceExpiration(nsSpec)
+		if err != nil {
+			return c.sendRequestError(ctx, "", groupMemberError(i, err))
+		}
+		if expiresAt.IsZero() || memberExpiresAt.Before(expiresAt) {
+			expiresAt = memberExpiresAt
+		}
+		nsSpec.Labels[groupLabel] = group
+		specs[i] = nsSpec
+		resources[i] = member.Resources
+	}
+
+	// the group is admitted as a whole or not at all
+	if err := c.checkGlobalLimits(resources...); err != nil {
+		return c.sendRequestError(ctx, "", err)
+	}
+
+	now := time.Now()
+	results := make([]*provisioningResult, len(specs))
+	steps := make([]provisioningStep, len(specs))
+	for i, nsSpec := range specs {
+		setExpiry(nsSpec, now, expiresAt)
+		steps[i] = provisioningStep{
+			name: nsSpec.Name,
+			run: func(ctx context.Context) error {
+				var err error
+				results[i], err = c.provisionNamespace(ctx, nsSpec, &req.Namespaces[i], nil, namespaceList, nil)
+				return err
+			},
+			undo: func(ctx context.Context) error {
+				return c.clientset.CoreV1().Namespaces().Delete(ctx, results[i].namespace, metav1.DeleteOptions{})
+			},
+		}
+	}
+
+	slog.Info("Creating namespace group", "group", group, "namespaces", len(specs), "user", authenticatedUser(ctx))
+	if err := runProvisioningSteps(context.TODO(), group, steps, nil); err != nil {
+		slog.Error("Error creating namespace group", "group", group, "error", err)
+		return c.sendErrorResponse(ctx, "", "Error creating namespace group: "+err.Error(), provisioningErrorStatus(err))
+	}
+
+	response := models.PostNamespace200Response{
+		Message: "Namespace group created",
+		Group:   group,
+	}
+	kubeconfigs := make(map[string][]byte, len(results))
+	for _, result := range results {
+		response.Namespaces = append(response.Namespaces, result.namespace)
+		kubeconfigs[result.namespace] = result.kubeconfig
+	}
+	if !req.SharedKubeconfig {
+		response.KubeConfigs = kubeconfigs
+		return ctx.JSON(http.StatusOK, response)
+	}
+
+	response.KubeConfig, err = mergeKubeconfigs(response.Namespaces, kubeconfigs)
+	if err != nil {
+		// the namespaces exist, the kubeconfigs are still usable one by one
+		slog.Error("Error merging kubeconfigs", "group", group, "error", err)
+		response.KubeConfigs = kubeconfigs
+	}
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// groupMemberError prefixes the message of a rejected group member with its position
+func groupMemberError(index int, err error) error {
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return echo.NewHTTPError(he.Code, fmt.Sprintf("Namespace %d: %v", index+1, he.Message))
+	}
+	return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Namespace %d: %v", index+1, err))
+}
+
+// mergeKubeconfigs combines the kubeconfigs of the namespaces of a group into one with
+// a context per namespace. Contexts and users are named after the namespaces, because
+// the service accounts of all namespaces share the same name.
+func mergeKubeconfigs(namespaces []string, kubeconfigs map[string][]byte) ([]byte, error) {
+	merged := clientcmdapi.NewConfig()
+	for _, namespace := range namespaces {
+		cfg, err := clientcmd.Load(kubeconfigs[namespace])
+		if err != nil {
+			return nil, fmt.Errorf("invalid kubeconfig of namespace %s: %w", namespace, err)
+		}
+		current, ok := cfg.Contexts[cfg.CurrentContext]
+		if !ok {
+			return nil, fmt.Errorf("kubeconfig of namespace %s has no current context", namespace)
+		}
+		for name, cluster := range cfg.Clusters {
+			merged.Clusters[name] = cluster
+		}
+		merged.AuthInfos[namespace] = cfg.AuthInfos[current.AuthInfo]
+		merged.Contexts[namespace] = &clientcmdapi.Context{
+			Cluster:   current.Cluster,
+			AuthInfo:  namespace,
+			Namespace: current.Namespace,
+		}
+	}
+	merged.CurrentContext = namespaces[0]
+	return convertKubeconfigToYaml(merged)
+}
+
+// groupNamespaces returns the namespaces of a group sorted by name. A group that is
+// invalid or has no namespaces is returned as *echo.HTTPError.
+func (c *Container) groupNamespaces(ctx context.Context, group string) ([]v1.Namespace, error) {
+	if errs := validation.IsValidLabelValue(group); group == "" || len(errs) > 0 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid group "+group)
+	}
+	list, err := c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: "created-by=tenama," + groupLabel + "=" + group,
+	})
+	if err != nil {
+		slog.Error("Error listing namespaces of group", "group", group, "error", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Error listing namespaces of group")
+	}
+	if len(list.Items) == 0 {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Group not found")
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	return list.Items, nil
+}
+
+// namespaceGroupResponse lists the namespaces of a group and the earliest expiry among them
+func namespaceGroupResponse(message string, group string, namespaces []v1.Namespace) models.GetNamespaceGroup200Response {
+	response := models.GetNamespaceGroup200Response{Message: message, Group: group}
+	var expiresAt time.Time
+	for i := range namespaces {
+		response.Namespaces = append(response.Namespaces, namespaces[i].Name)
+		if t, err := namespaceExpiration(&namespaces[i]); err == nil && (expiresAt.IsZero() || t.Before(expiresAt)) {
+			expiresAt = t
+		}
+	}
+	if !expiresAt.IsZero() {
+		response.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	}
+	return response
+}
+
+// GetNamespaceGroup - Lists the namespaces of a group
+func (c *Container) GetNamespaceGroup(ctx echo.Context) error {
+	group := strings.Trim(ctx.Param("group"), "/")
+	namespaces, err := c.groupNamespaces(context.TODO(), group)
+	if err != nil {
+		return c.sendRequestError(ctx, "", err)
+	}
+	return ctx.JSON(http.StatusOK, namespaceGroupResponse("Namespace group successfully retrieved", group, namespaces))
+}
+
+// ExtendNamespaceGroup - Extends the lifetime of all namespaces of a group. The
+// extension is checked for every namespace before any of them is updated.
+func (c *Container) ExtendNamespaceGroup(ctx echo.Context) error {
+	group := strings.Trim(ctx.Param("group"), "/")
+
+	if !c.config.Namespace.Extension.Enabled {
+		return c.sendErrorResponse(ctx, "", "Namespace extension is disabled", http.StatusForbidden)
+	}
+
+	req := models.NamespaceExtension{}
+	if err := ctx.Bind(&req); err != nil {
+		slog.Error("Error parsing extension request", "error", err)
+		return c.sendErrorResponse(ctx, "", "Error parsing extension request", http.StatusBadRequest)
+	}
+
+	extension, err := parseDuration(req.Duration)
+	if err != nil || extension <= 0 {
+		slog.Warn("Error parsing duration", "duration", req.Duration)
+		return c.sendErrorResponse(ctx, "", "Error parsing duration", http.StatusBadRequest)
+	}
+
+	policy, err := durationPolicyFor(c.config, authenticatedUser(ctx))
+	if err != nil {
+		slog.Error("Invalid duration policy", "error", err)
+		return c.sendErrorResponse(ctx, "", "Invalid duration policy", http.StatusInternalServerError)
+	}
+
+	namespaces, err := c.groupNamespaces(context.TODO(), group)
+	if err != nil {
+		return c.sendRequestError(ctx, "", err)
+	}
+
+	extended := make([]*v1.Namespace, len(namespaces))
+	for i := range namespaces {
+		ns := namespaces[i].DeepCopy()
+		if ns.Labels[stateLabel] == stateExpired {
+			return c.sendErrorResponse(ctx, ns.Name, "Namespace has expired, the group cannot be extended", http.StatusConflict)
+		}
+		expiresAt, err := extendLease(ns, extension, c.config.Namespace.Extension)
+		if err != nil {
+			slog.Warn("Namespace group extension rejected", "group", group, "namespace", ns.Name, "error", err)
+			if errors.Is(err, errExtensionNotAllowed) {
+				return c.sendErrorResponse(ctx, ns.Name, err.Error(), http.StatusForbidden)
+			}
+			return c.sendErrorResponse(ctx, ns.Name, "Error extending namespace", http.StatusInternalServerError)
+		}
+		if err := policy.check(expiresAt.Sub(ns.CreationTimestamp.Time)); err != nil {
+			slog.Warn("Namespace group extension rejected", "group", group, "namespace", ns.Name, "error", err)
+			return c.sendErrorResponse(ctx, ns.Name, err.Error(), http.StatusBadRequest)
+		}
+		extended[i] = ns
+	}
+
+	// The watcher re-arms the cleanup timers when it receives the MODIFIED events
+	for i, ns := range extended {
+		updated, err := c.clientset.CoreV1().Namespaces().Update(context.TODO(), ns, metav1.UpdateOptions{})
+		if err != nil {
+			slog.Error("Error updating namespace", "group", group, "namespace", ns.Name, "error", err)
+			c.revertGroupUpdate(group, namespaces[:i], extended[:i])
+			if apierrors.IsConflict(err) {
+				return c.sendErrorResponse(ctx, ns.Name, "Namespace was modified concurrently, please retry", http.StatusConflict)
+			}
+			return c.sendErrorResponse(ctx, ns.Name, "Error extending namespace group", http.StatusInternalServerError)
+		}
+		extended[i] = updated
+	}
+
+	slog.Info("Extended namespace group", "group", group, "extension", extension.String())
+	for i, ns := range extended {
+		namespaces[i] = *ns
+	}
+	// the tokens of earlier kubeconfigs expire with the previous lifetime
+	kubeconfigs, failed := c.renewGroupKubeconfigs(ctx, group, extended)
+	if len(failed) > 0 {
+		return c.sendErrorResponse(ctx, "", "Namespace group extended, but the kubeconfigs of "+strings.Join(failed, ", ")+" could not be renewed", http.StatusInternalServerError)
+	}
+	response := namespaceGroupResponse("Namespace group successfully extended", group, namespaces)
+	response.KubeConfigs = kubeconfigs
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// renewGroupKubeconfigs returns the renewed kubeconfigs of the namespaces of a group
+// that the user created or is bound to, and the namespaces whose renewal failed
+func (c *Container) renewGroupKubeconfigs(ctx echo.Context, group string, namespaces []*v1.Namespace) (map[string][]byte, []string) {
+	kubeconfigs := make(map[string][]byte, len(namespaces))
+	var failed []string
+	for _, ns := range namespaces {
+		expiresAt, err := namespaceExpiration(ns)
+		var kubeconfig []byte
+		if err == nil {
+			kubeconfig, err = c.renewKubeconfig(ctx, ns, expiresAt)
+		}
+		if err != nil {
+			slog.Error("Error renewing kubeconfig", "group", group, "namespace", ns.Name, "error", err)
+			failed = append(failed, ns.Name)
+			continue
+		}
+		if kubeconfig != nil {
+			kubeconfigs[ns.Name] = kubeconfig
+		}
+	}
+	return kubeconfigs, failed
+}
+
+// RestoreNamespaceGroup - Restores the expired namespaces of a group with a shared
+// lease. All namespaces must be expired before any of them is restored.
+func (c *Container) RestoreNamespaceGroup(ctx echo.Context) error {
+	group := strings.Trim(ctx.Param("group"), "/")
+
+	req := models.NamespaceRestore{}
+	if err := ctx.Bind(&req); err != nil {
+		slog.Error("Error parsing restore request", "error", err)
+		return c.sendErrorResponse(ctx, "", "Error parsing restore request", http.StatusBadRequest)
+	}
+
+	policy, err := durationPolicyFor(c.config, authenticatedUser(ctx))
+	if err != nil {
+		slog.Error("Invalid duration policy", "error", err)
+		return c.sendErrorResponse(ctx, "", "Invalid duration policy", http.StatusInternalServerError)
+	}
+	if req.Duration == "" && policy.def > 0 {
+		req.Duration = policy.def.String()
+	}
+
+	lease, err := parseDuration(req.Duration)
+	if err != nil || lease <= 0 {
+		slog.Warn("Error parsing duration", "duration", req.Duration)
+		return c.sendErrorResponse(ctx, "", "Error parsing duration", http.StatusBadRequest)
+	}
+	if err := policy.check(lease); err != nil {
+		slog.Warn("Namespace group restore rejected", "group", group, "error", err)
+		return c.sendErrorResponse(ctx, "", err.Error(), http.StatusBadRequest)
+	}
+
+	namespaces, err := c.groupNamespaces(context.TODO(), group)
+	if err != nil {
+		return c.sendRequestError(ctx, "", err)
+	}
+
+	now := time.Now()
+	restored := make([]*v1.Namespace, len(namespaces))
+	for i := range namespaces {
+		ns := namespaces[i].DeepCopy()
+		if ns.Labels[stateLabel] != stateExpired {
+			return c.sendErrorResponse(ctx, ns.Name, "Namespace is not expired, the group cannot be restored", http.StatusConflict)
+		}
+		// Grant the new lease first so that the watcher replaces the grace period deletion timer
+		delete(ns.Labels, stateLabel)
+		delete(ns.Annotations, expiredAtAnnotation)
+		grantLease(ns, lease, now)
+		restored[i] = ns
+	}
+
+	for i, ns := range restored {
+		updated, err := c.clientset.CoreV1().Namespaces().Update(context.TODO(), ns, metav1.UpdateOptions{})
+		if err != nil {
+			slog.Error("Error updating namespace", "group", group, "namespace", ns.Name, "error", err)
+			c.revertGroupUpdate(group, namespaces[:i], restored[:i])
+			if apierrors.IsConflict(err) {
+				return c.sendErrorResponse(ctx, ns.Name, "Namespace was modified concurrently, please retry", http.StatusConflict)
+			}
+			return c.sendErrorResponse(ctx, ns.Name, "Error restoring namespace group", http.StatusInternalServerError)
+		}
+		restored[i] = updated
+	}
+
+	var failed []string
+	for _, ns := range restored {
+		if err := restoreWorkloads(context.TODO(), c.clientset.AppsV1(), ns.Name); err != nil {
+			slog.Error("Error restoring workloads", "group", group, "namespace", ns.Name, "error", err)
+			failed = append(failed, ns.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return c.sendErrorResponse(ctx, "", "Namespace group restored, but some workloads could not be scaled up in "+strings.Join(failed, ", "), http.StatusInternalServerError)
+	}
+
+	slog.Info("Restored namespace group", "group", group, "lease", lease.String())
+	for i, ns := range restored {
+		namespaces[i] = *ns
+	}
+	// the tokens of earlier kubeconfigs expired with the namespaces
+	kubeconfigs, failed := c.renewGroupKubeconfigs(ctx, group, restored)
+	if len(failed) > 0 {
+		return c.sendErrorResponse(ctx, "", "Namespace group restored, but the kubeconfigs of "+strings.Join(failed, ", ")+" could not be renewed", http.StatusInternalServerError)
+	}
+	response := namespaceGroupResponse("Namespace group successfully restored", group, namespaces)
+	response.KubeConfigs = kubeconfigs
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// revertGroupUpdate restores the namespaces that were already updated when the
+// update of a later namespace of the group failed
+func (c *Container) revertGroupUpdate(group string, original []v1.Namespace, updated []*v1.Namespace) {
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	for i, ns := range updated {
+		reverted := original[i].DeepCopy()
+		reverted.ResourceVersion = ns.ResourceVersion
+		if _, err := c.clientset.CoreV1().Namespaces().Update(ctx, reverted, metav1.UpdateOptions{}); err != nil {
+			slog.Error("Error reverting namespace update", "group", group, "namespace", ns.Name, "error", err)
+		}
+	}
+}
+
+// DeleteNamespaceGroup - Deletes all namespaces of a group
+func (c *Container) DeleteNamespaceGroup(ctx echo.Context) error {
+	group := strings.Trim(ctx.Param("group"), "/")
+	namespaces, err := c.groupNamespaces(context.TODO(), group)
+	if err != nil {
+		return c.sendRequestError(ctx, "", err)
+	}
+
+	slog.Info("Delete namespace group through an API call", "group", group, "namespaces", len(namespaces))
+	var failed []string
+	for i := range namespaces {
+		if c.hooks != nil {
+			c.hooks.RunPreDelete(&namespaces[i], HookTriggerAPI)
+		}
+		err := c.clientset.CoreV1().Namespaces().Delete(context.TODO(), namespaces[i].Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			slog.Error("Error deleting namespace", "group", group, "namespace", namespaces[i].Name, "error", err)
+			failed = append(failed, namespaces[i].Name)
+		}
+	}
+	if len(failed) > 0 {
+		return c.sendErrorResponse(ctx, "", "Error deleting namespaces "+strings.Join(failed, ", "), http.StatusInternalServerError)
+	}
+
+	return ctx.JSON(http.StatusOK, namespaceGroupResponse("Namespace group successfully deleted", group, namespaces))
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func groupMember(name string, group string, expiresAt string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			Labels: map[string]string{
+				"created-by":                "tenama",
+				"tenama/namespace-duration": "2h0m0s",
+				groupLabel:                  group,
+			},
+			Annotations: map[string]string{expiresAtAnnotation: expiresAt, creatorAnnotation: "jane"},
+		},
+	}
+}
+
+func groupContext(method string, group string, body string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, "/groups/"+group, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(req, rec)
+	ctx.SetParamNames("group")
+	ctx.SetParamValues(group)
+	return ctx, rec
+}
+
+func TestMergeKubeconfigs(t *testing.T) {
+	kubeconfigs := map[string][]byte{}
+	for _, namespace := range []string{"tenama-api-abcde", "tenama-db-fghij"} {
+		cfg := clientcmdapi.NewConfig()
+		cfg.Clusters["default"] = &clientcmdapi.Cluster{Server: "https://cluster.example.com"}
+		cfg.AuthInfos["tenama-sa"] = &clientcmdapi.AuthInfo{Token: "token-" + namespace}
+		cfg.Contexts["tenama-sa"] = &clientcmdapi.Context{Cluster: "default", AuthInfo: "tenama-sa", Namespace: namespace}
+		cfg.CurrentContext = "tenama-sa"
+		kubeconfig, err := convertKubeconfigToYaml(cfg)
+		if err != nil {
+			t.Fatalf("convertKubeconfigToYaml returned error: %v", err)
+		}
+		kubeconfigs[namespace] = kubeconfig
+	}
+
+	merged, err := mergeKubeconfigs([]string{"tenama-api-abcde", "tenama-db-fghij"}, kubeconfigs)
+	if err != nil {
+		t.Fatalf("mergeKubeconfigs returned error: %v", err)
+	}
+	cfg, err := clientcmd.Load(merged)
+	if err != nil {
+		t.Fatalf("Expected a valid kubeconfig, got %v", err)
+	}
+	if cfg.CurrentContext != "tenama-api-abcde" || len(cfg.Contexts) != 2 || len(cfg.Clusters) != 1 {
+		t.Errorf("Expected a context per namespace, got %+v", cfg)
+	}
+	for _, namespace := range []string{"tenama-api-abcde", "tenama-db-fghij"} {
+		current := cfg.Contexts[namespace]
+		if current == nil || current.Namespace != namespace || cfg.AuthInfos[current.AuthInfo].Token != "token-"+namespace {
+			t.Errorf("Expected context with the token of %s, got %+v", namespace, current)
+		}
+	}
+}
+
+func TestGetNamespaceGroup(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		groupMember("tenama-db-fghij", "g1", "2025-01-03T12:00:00Z"),
+		groupMember("tenama-api-abcde", "g1", "2025-01-03T12:00:00Z"),
+		groupMember("tenama-other-klmno", "g2", "2025-01-04T12:00:00Z"),
+	)
+	c := &Container{clientset: clientset, config: &models.Config{}}
+
+	ctx, rec := groupContext(http.MethodGet, "g1", "")
+	if err := c.GetNamespaceGroup(ctx); err != nil {
+		t.Fatalf("GetNamespaceGroup returned error: %v", err)
+	}
+	var response models.GetNamespaceGroup200Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid response %s: %v", rec.Body.String(), err)
+	}
+	want := []string{"tenama-api-abcde", "tenama-db-fghij"}
+	if rec.Code != http.StatusOK || !reflect.DeepEqual(response.Namespaces, want) || response.ExpiresAt != "2025-01-03T12:00:00Z" {
+		t.Errorf("Expected %v, got %d %+v", want, rec.Code, response)
+	}
+
+	for group, status := range map[string]int{"missing": http.StatusNotFound, "in,valid": http.StatusBadRequest} {
+		ctx, rec := groupContext(http.MethodGet, group, "")
+		if err := c.GetNamespaceGroup(ctx); err != nil {
+			t.Fatalf("GetNamespaceGroup returned error: %v", err)
+		}
+		if rec.Code != status {
+			t.Errorf("%s: expected status %d, got %d", group, status, rec.Code)
+		}
+	}
+}
+
+// TestExtendNamespaceGroup tests that a group is only extended if all its namespaces may be extended
+func TestExtendNamespaceGroup(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	exhausted := groupMember("tenama-db-pqrst", "g2", expiresAt)
+	exhausted.Labels[extensionCountLabel] = "1"
+	clientset := fake.NewSimpleClientset(
+		groupMember("tenama-api-abcde", "g1", expiresAt),
+		groupMember("tenama-db-fghij", "g1", expiresAt),
+		groupMember("tenama-api-klmno", "g2", expiresAt),
+		exhausted,
+	)
+	cfg := &models.Config{}
+	cfg.Namespace.Extension = models.Extension{Enabled: true, MaxExtensions: 1}
+	var expirations []int64
+	tokenReactor(clientset, &expirations)
+	c := &Container{clientset: clientset, config: cfg}
+
+	ctx, rec := groupContext(http.MethodPatch, "g1", `{"duration":"1h"}`)
+	ctx.Set(authUserKey, "jane")
+	if err := c.ExtendNamespaceGroup(ctx); err != nil {
+		t.Fatalf("ExtendNamespaceGroup returned error: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response models.GetNamespaceGroup200Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || len(response.KubeConfigs) != 2 {
+		t.Errorf("Expected renewed kubeconfigs, got %+v (%v)", response, err)
+	}
+	for _, name := range []string{"tenama-api-abcde", "tenama-db-fghij"} {
+		ns, _ := clientset.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+		if ns.Labels[extensionCountLabel] != "1" || ns.Annotations[expiresAtAnnotation] == expiresAt {
+			t.Errorf("Expected %s to be extended, got %+v", name, ns.ObjectMeta)
+		}
+	}
+
+	ctx, rec = groupContext(http.MethodPatch, "g2", `{"duration":"1h"}`)
+	if err := c.ExtendNamespaceGroup(ctx); err != nil {
+		t.Fatalf("ExtendNamespaceGroup returned error: %v", err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", rec.Code)
+	}
+	ns, _ := clientset.CoreV1().Namespaces().Get(context.Background(), "tenama-api-klmno", metav1.GetOptions{})
+	if ns.Annotations[expiresAtAnnotation] != expiresAt {
+		t.Errorf("Expected the group to be left unchanged, got %+v", ns.ObjectMeta)
+	}
+}
+
+// TestExtendNamespaceGroupMaxLifetime tests that group extensions are checked against the total lifetime
+func TestExtendNamespaceGroupMaxLifetime(t *testing.T) {
+	// created an hour ago, one hour remains
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	clientset := fake.NewSimpleClientset(
+		groupMember("tenama-api-abcde", "g1", expiresAt),
+		groupMember("tenama-db-fghij", "g1", expiresAt),
+	)
+	cfg := &models.Config{}
+	cfg.Namespace.Extension.Enabled = true
+	cfg.Namespace.DurationPolicy.Max = "150m"
+	c := &Container{clientset: clientset, config: cfg}
+
+	ctx, rec := groupContext(http.MethodPatch, "g1", `{"duration":"1h"}`)
+	if err := c.ExtendNamespaceGroup(ctx); err != nil {
+		t.Fatalf("ExtendNamespaceGroup returned error: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a total lifetime of 3h, got %d: %s", rec.Code, rec.Body.String())
+	}
+	ns, _ := clientset.CoreV1().Namespaces().Get(context.Background(), "tenama-api-abcde", metav1.GetOptions{})
+	if ns.Annotations[expiresAtAnnotation] != expiresAt {
+		t.Errorf("Expected the group to be left unchanged, got %+v", ns.ObjectMeta)
+	}
+}
+
+func TestDeleteNamespaceGroup(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		groupMember("tenama-api-abcde", "g1", "2025-01-03T12:00:00Z"),
+		groupMember("tenama-db-fghij", "g1", "2025-01-03T12:00:00Z"),
+		groupMember("tenama-other-klmno", "g2", "2025-01-03T12:00:00Z"),
+	)
+	c := &Container{clientset: clientset, config: &models.Config{}}
+
+	ctx, rec := groupContext(http.MethodDelete, "g1", "")
+	if err := c.DeleteNamespaceGroup(ctx); err != nil {
+		t.Fatalf("DeleteNamespaceGroup returned error: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	list, _ := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if len(list.Items) != 1 || list.Items[0].Name != "tenama-other-klmno" {
+		t.Errorf("Expected only the other group to be left, got %+v", list.Items)
+	}
+}
+
+// TestCreateNamespaceGroup tests that the namespaces of a group share their expiry and kubeconfig
+func TestCreateNamespaceGroup(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	var expirations []int64
+	tokenReactor(clientset, &expirations)
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	c := &Container{clientset: clientset, config: cfg}
+	c.SetCluster("https://cluster.example.com", nil)
+
+	body := `{"duration":"2h","sharedKubeconfig":true,"namespaces":[{"infix":"api"},{"infix":"db"}]}`
+	ctx, rec := groupContext(http.MethodPost, "", body)
+	if err := c.CreateNamespaceGroup(ctx); err != nil {
+		t.Fatalf("CreateNamespaceGroup returned error: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response models.PostNamespace200Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid response %s: %v", rec.Body.String(), err)
+	}
+	if response.Group == "" || len(response.Namespaces) != 2 {
+		t.Fatalf("Expected a group of 2 namespaces, got %+v", response)
+	}
+
+	kubeconfig, err := clientcmd.Load(response.KubeConfig)
+	if err != nil || len(kubeconfig.Contexts) != 2 {
+		t.Errorf("Expected a shared kubeconfig with a context per namespace, got %+v (%v)", kubeconfig, err)
+	}
+	var expiresAt []string
+	for _, name := range response.Namespaces {
+		ns, err := clientset.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil || ns.Labels[groupLabel] != response.Group {
+			t.Fatalf("Expected %s in the group, got %v", name, err)
+		}
+		expiresAt = append(expiresAt, ns.Annotations[expiresAtAnnotation])
+	}
+	if expiresAt[0] != expiresAt[1] {
+		t.Errorf("Expected a shared expiry, got %v", expiresAt)
+	}
+}
+
+// TestCreateNamespaceGroupLimits tests that the namespaces of a group are checked against the global limits together
+func TestCreateNamespaceGroupLimits(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	cfg.GlobalLimits.Enabled = true
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	watcher.SetGlobalLimits(v1.ResourceList{v1.ResourceCPU: resource.MustParse("3")})
+	c := &Container{clientset: clientset, config: cfg, watcher: watcher}
+
+	body := `{"duration":"1h","namespaces":[{"infix":"api","resources":{"cpu":"2"}},{"infix":"db","resources":{"cpu":"2"}}]}`
+	ctx, rec := groupContext(http.MethodPost, "", body)
+	if err := c.CreateNamespaceGroup(ctx); err != nil {
+		t.Fatalf("CreateNamespaceGroup returned error: %v", err)
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	ctx, rec = groupContext(http.MethodPost, "", `{"duration":"1h","namespaces":[{"infix":"api"},{"infix":"db","template":"missing"}]}`)
+	if err := c.CreateNamespaceGroup(ctx); err != nil {
+		t.Fatalf("CreateNamespaceGroup returned error: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Namespace 2") {
+		t.Errorf("Expected the second namespace to be rejected, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	ctx, rec = groupContext(http.MethodPost, "", `{"namespaces":[]}`)
+	if err := c.CreateNamespaceGroup(ctx); err != nil {
+		t.Fatalf("CreateNamespaceGroup returned error: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty group, got %d", rec.Code)
+	}
+}
+
+// TestExtendNamespaceInGroup tests that the namespaces of a group are only extended together
+func TestExtendNamespaceInGroup(t *testing.T) {
+	clientset := fake.NewSimpleClientset(groupMember("tenama-api-abcde", "g1", time.Now().Add(time.Hour).UTC().Format(time.RFC3339)))
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	cfg.Namespace.Extension = models.Extension{Enabled: true}
+	c := &Container{clientset: clientset, config: cfg}
+
+	req := httptest.NewRequest(http.MethodPatch, "/namespace/tenama-api-abcde/extend", strings.NewReader(`{"duration":"1h"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(req, rec)
+	ctx.SetParamNames("namespace")
+	ctx.SetParamValues("tenama-api-abcde")
+	if err := c.ExtendNamespace(ctx); err != nil {
+		t.Fatalf("ExtendNamespace returned error: %v", err)
+	}
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", rec.Code)
+	}
+}
+
+// TestDeleteAndRestoreNamespaceInGroup tests that the namespaces of a group are only deleted and restored together
+func TestDeleteAndRestoreNamespaceInGroup(t *testing.T) {
+	ns := groupMember("tenama-api-abcde", "g1", time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+	ns.Labels[stateLabel] = stateExpired
+	clientset := fake.NewSimpleClientset(ns)
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	c := &Container{clientset: clientset, config: cfg}
+
+	ctx, rec := namespaceContext(http.MethodPost, ns.Name, `{"duration":"1h"}`)
+	if err := c.RestoreNamespace(ctx); err != nil {
+		t.Fatalf("RestoreNamespace returned error: %v", err)
+	}
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for restore, got %d", rec.Code)
+	}
+
+	ctx, rec = namespaceContext(http.MethodDelete, ns.Name, "")
+	if err := c.DeleteNamespace(ctx); err != nil {
+		t.Fatalf("DeleteNamespace returned error: %v", err)
+	}
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for delete, got %d", rec.Code)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), ns.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected namespace to be kept, got %v", err)
+	}
+}
+
+func TestRestoreNamespaceGroup(t *testing.T) {
+	expired := func(name string, group string) *v1.Namespace {
+		ns := groupMember(name, group, time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+		ns.Labels[stateLabel] = stateExpired
+		ns.Annotations[expiredAtAnnotation] = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+		return ns
+	}
+	clientset := fake.NewSimpleClientset(
+		expired("tenama-api-abcde", "g1"),
+		expired("tenama-db-fghij", "g1"),
+		expired("tenama-api-klmno", "g2"),
+		groupMember("tenama-db-pqrst", "g2", time.Now().Add(time.Hour).UTC().Format(time.RFC3339)),
+	)
+	var expirations []int64
+	tokenReactor(clientset, &expirations)
+	c := &Container{clientset: clientset, config: &models.Config{}}
+
+	ctx, rec := groupContext(http.MethodPost, "g1", `{"duration":"2h"}`)
+	if err := c.RestoreNamespaceGroup(ctx); err != nil {
+		t.Fatalf("RestoreNamespaceGroup returned error: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var expiresAt []string
+	for _, name := range []string{"tenama-api-abcde", "tenama-db-fghij"} {
+		ns, _ := clientset.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+		if ns.Labels[stateLabel] != "" || ns.Annotations[expiredAtAnnotation] != "" {
+			t.Errorf("Expected %s to be restored, got %+v", name, ns.ObjectMeta)
+		}
+		expiresAt = append(expiresAt, ns.Annotations[expiresAtAnnotation])
+	}
+	if expiresAt[0] != expiresAt[1] {
+		t.Errorf("Expected a shared expiry, got %v", expiresAt)
+	}
+
+	ctx, rec = groupContext(http.MethodPost, "g2", `{"duration":"2h"}`)
+	if err := c.RestoreNamespaceGroup(ctx); err != nil {
+		t.Fatalf("RestoreNamespaceGroup returned error: %v", err)
+	}
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a group that is not expired, got %d", rec.Code)
+	}
+	ns, _ := clientset.CoreV1().Namespaces().Get(context.Background(), "tenama-api-klmno", metav1.GetOptions{})
+	if ns.Labels[stateLabel] != stateExpired {
+		t.Errorf("Expected the group to be left unchanged, got %+v", ns.ObjectMeta)
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	stateHibernated = "hibernated"
+
+	hibernateScheduleAnnotation   = "tenama/hibernate-schedule"
+	wakeUpScheduleAnnotation      = "tenama/wake-up-schedule"
+	hibernationTimeZoneAnnotation = "tenama/hibernation-timezone"
+
+	// hibernationRetryDelay is used when a hibernation transition failed
+	hibernationRetryDelay = time.Minute
+)
+
+// hibernationSchedule describes when a namespace is scaled to zero and back up again
+type hibernationSchedule struct {
+	hibernate *cronSchedule
+	wakeUp    *cronSchedule
+}
+
+// parseHibernationSchedule parses the hibernate and wake-up cron expressions in the given
+// time zone. An empty time zone means UTC.
+func parseHibernationSchedule(hibernate string, wakeUp string, timeZone string) (*hibernationSchedule, error) {
+	if hibernate == "" || wakeUp == "" {
+		return nil, errors.New("hibernate and wake-up schedule are both required")
+	}
+
+	location := time.UTC
+	if timeZone != "" {
+		var err error
+		location, err = time.LoadLocation(timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+		}
+	}
+
+	hibernateSchedule, err := parseCron(hibernate, location)
+	if err != nil {
+		return nil, err
+	}
+	wakeUpSchedule, err := parseCron(wakeUp, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hibernationSchedule{hibernate: hibernateSchedule, wakeUp: wakeUpSchedule}, nil
+}
+
+// state reports whether a namespace should be hibernated at t and when that changes next
+func (hs *hibernationSchedule) state(t time.Time) (bool, time.Time) {
+	nextHibernate := hs.hibernate.Next(t)
+	nextWakeUp := hs.wakeUp.Next(t)
+
+	if !nextWakeUp.IsZero() && (nextHibernate.IsZero() || nextWakeUp.Before(nextHibernate)) {
+		return true, nextWakeUp
+	}
+	return false, nextHibernate
+}
+
+// validateHibernation checks the hibernation schedule of a namespace request
+func validateHibernation(h *models.Hibernation) error {
+	if h == nil {
+		return nil
+	}
+	_, err := parseHibernationSchedule(h.Hibernate, h.WakeUp, h.TimeZone)
+	return err
+}
+
+// hibernationAnnotations returns the annotations that store the hibernation schedule on a namespace
+func hibernationAnnotations(h *models.Hibernation) map[string]string {
+	if h == nil {
+		return nil
+	}
+	annotations := map[string]string{
+		hibernateScheduleAnnotation: h.Hibernate,
+		wakeUpScheduleAnnotation:    h.WakeUp,
+	}
+	if h.TimeZone != "" {
+		annotations[hibernationTimeZoneAnnotation] = h.TimeZone
+	}
+	return annotations
+}
+
+// SetHibernation enables scheduled hibernation. The default schedule applies to all
+// namespaces without their own schedule and may be empty.
+func (nw *NamespaceWatcher) SetHibernation(workloadGetter WorkloadGetter, hibernate string, wakeUp string, timeZone string) error {
+	var defaultSchedule *hibernationSchedule
+	if hibernate != "" || wakeUp != "" {
+		var err error
+		defaultSchedule, err = parseHibernationSchedule(hibernate, wakeUp, timeZone)
+		if err != nil {
+			return err
+		}
+	}
+
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	nw.workloadGetter = workloadGetter
+	nw.hibernationEnabled = true
+	nw.defaultHibernation = defaultSchedule
+	return nil
+}
+
+// SetHibernatedCapacity sets the percentage of its requested resources that a
+// hibernated namespace counts against the global limits
+func (nw *NamespaceWatcher) SetHibernatedCapacity(percent int) {
+	nw.resourceMu.Lock()
+	defer nw.resourceMu.Unlock()
+	nw.hibernatedCapacity = percent
+}
+
+// hibernationScheduleFor returns the schedule of a namespace, preferring its own
+// annotations over the default schedule
+func (nw *NamespaceWatcher) hibernationScheduleFor(ns *v1.Namespace) (*hibernationSchedule, error) {
+	if _, ok := ns.Annotations[hibernateScheduleAnnotation]; ok {
+		return parseHibernationSchedule(
+			ns.Annotations[hibernateScheduleAnnotation],
+			ns.Annotations[wakeUpScheduleAnnotation],
+			ns.Annotations[hibernationTimeZoneAnnotation],
+		)
+	}
+	return nw.defaultHibernation, nil
+}
+
+// scheduleHibernation replaces the hibernation timer of a namespace. The timer fires
+// immediately if the namespace is not in the state its schedule demands.
+// Callers must hold nw.mu.
+func (nw *NamespaceWatcher) scheduleHibernation(ns *v1.Namespace) {
+	if existing, ok := nw.hibernationTimers[ns.Name]; ok {
+		existing.Stop()
+		delete(nw.hibernationTimers, ns.Name)
+	}
+
+	if !nw.hibernationEnabled || ns.Labels[stateLabel] == stateExpired {
+		return
+	}
+
+	schedule, err := nw.hibernationScheduleFor(ns)
+	if err != nil {
+		slog.Error("Invalid hibernation schedule", "namespace", ns.Name, "error", err)
+		return
+	}
+	if schedule == nil {
+		return
+	}
+
+	hibernated, next := schedule.state(time.Now())
+	if next.IsZero() {
+		return
+	}
+
+	delay := time.Until(next)
+	if hibernated != (ns.Labels[stateLabel] == stateHibernated) {
+		delay = 0
+	}
+
+	name := ns.Name
+	nw.hibernationTimers[name] = time.AfterFunc(delay, func() {
+		nw.hibernationTransition(name)
+	})
+	slog.Debug("Scheduled hibernation transition", "namespace", name, "duration", delay.String())
+}
+
+// hibernationTransition scales the workloads of a namespace down or up according to
+// its hibernation schedule and records the state in the namespace labels
+func (nw *NamespaceWatcher) hibernationTransition(namespaceName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	nw.mu.RLock()
+	workloadGetter := nw.workloadGetter
+	leading := nw.leading
+	nw.mu.RUnlock()
+	if !leading {
+		return
+	}
+
+	ns, err := nw.namespaceGetter.Namespaces().Get(ctx, namespaceName, metav1.GetOptions{})
+	if err != nil {
+		slog.Error("Error getting namespace for hibernation", "namespace", namespaceName, "error", err)
+		return
+	}
+
+	nw.mu.RLock()
+	schedule, err := nw.hibernationScheduleFor(ns)
+	nw.mu.RUnlock()
+	if err != nil || schedule == nil || ns.Labels[stateLabel] == stateExpired {
+		return
+	}
+
+	hibernate, _ := schedule.state(time.Now())
+	if hibernate != (ns.Labels[stateLabel] == stateHibernated) {
+		ns, err = nw.applyHibernationState(ctx, workloadGetter, ns, hibernate)
+		if err != nil {
+			slog.Error("Error changing hibernation state, retrying", "namespace", namespaceName, "hibernate", hibernate, "error", err)
+			nw.mu.Lock()
+			// a watch event may have scheduled a transition in the meantime
+			if existing, ok := nw.hibernationTimers[namespaceName]; ok {
+				existing.Stop()
+			}
+			nw.hibernationTimers[namespaceName] = time.AfterFunc(hibernationRetryDelay, func() {
+				nw.hibernationTransition(namespaceName)
+			})
+			nw.mu.Unlock()
+			return
+		}
+	}
+
+	nw.mu.Lock()
+	nw.scheduleHibernation(ns)
+	nw.mu.Unlock()
+}
+
+// applyHibernationState scales the workloads and updates the state label of a namespace
+func (nw *NamespaceWatcher) applyHibernationState(ctx context.Context, workloadGetter WorkloadGetter, ns *v1.Namespace, hibernate bool) (*v1.Namespace, error) {
+	if hibernate {
+		slog.Info("Hibernating namespace", "namespace", ns.Name)
+		if err := scaleDownWorkloads(ctx, workloadGetter, ns.Name); err != nil {
+			return nil, err
+		}
+	} else {
+		slog.Info("Waking up namespace", "namespace", ns.Name)
+		if err := restoreWorkloads(ctx, workloadGetter, ns.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	var updated *v1.Namespace
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := nw.namespaceGetter.Namespaces().Get(ctx, ns.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if current.Labels == nil {
+			current.Labels = make(map[string]string)
+		}
+		if hibernate {
+			current.Labels[stateLabel] = stateHibernated
+		} else {
+			delete(current.Labels, stateLabel)
+		}
+		updated, err = nw.namespaceGetter.Namespaces().Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	return updated, err
+}
+
+// trackedResources returns the resources of a namespace that count against the global
+// limits. Callers must hold nw.resourceMu.
+func (nw *NamespaceWatcher) trackedResources(ns *v1.Namespace) v1.ResourceList {
+	resources := extractNamespaceResources(ns)
+	if ns.Labels[stateLabel] != stateHibernated || nw.hibernatedCapacity >= 100 {
+		return resources
+	}
+
+	scaled := make(v1.ResourceList, len(resources))
+	for key, val := range resources {
+		scaled[key] = *resource.NewMilliQuantity(val.MilliValue()*int64(nw.hibernatedCapacity)/100, val.Format)
+	}
+	return scaled
+}
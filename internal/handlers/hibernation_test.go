@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHibernationScheduleState(t *testing.T) {
+	schedule, err := parseHibernationSchedule("0 19 * * 1-5", "0 7 * * 1-5", "")
+	if err != nil {
+		t.Fatalf("parseHibernationSchedule returned error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		at             time.Time
+		wantHibernated bool
+		wantNext       time.Time
+	}{
+		{
+			name:           "working hours",
+			at:             time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC), // Monday
+			wantHibernated: false,
+			wantNext:       time.Date(2025, 1, 6, 19, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "weeknight",
+			at:             time.Date(2025, 1, 6, 23, 0, 0, 0, time.UTC),
+			wantHibernated: true,
+			wantNext:       time.Date(2025, 1, 7, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "weekend",
+			at:             time.Date(2025, 1, 11, 12, 0, 0, 0, time.UTC), // Saturday
+			wantHibernated: true,
+			wantNext:       time.Date(2025, 1, 13, 7, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hibernated, next := schedule.state(tt.at)
+			if hibernated != tt.wantHibernated {
+				t.Errorf("Expected hibernated=%v, got %v", tt.wantHibernated, hibernated)
+			}
+			if !next.Equal(tt.wantNext) {
+				t.Errorf("Expected next transition %s, got %s", tt.wantNext, next)
+			}
+		})
+	}
+}
+
+func TestParseHibernationScheduleErrors(t *testing.T) {
+	if _, err := parseHibernationSchedule("0 19 * * 1-5", "", ""); err == nil {
+		t.Error("Expected error for missing wake-up schedule")
+	}
+	if _, err := parseHibernationSchedule("0 19 * * 1-5", "0 7 * * 1-5", "Mars/Olympus"); err == nil {
+		t.Error("Expected error for invalid time zone")
+	}
+}
+
+// TestHibernatedCapacity tests that hibernated namespaces count reduced resources against the global limits
+func TestHibernatedCapacity(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	watcher.SetHibernatedCapacity(25)
+
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "tenama-test-1",
+			Labels: map[string]string{
+				"tenama/resource-cpu":    "2000m",
+				"tenama/resource-memory": "4Gi",
+			},
+		},
+	}
+	watcher.addToResourceTracking(ns)
+
+	usage := watcher.GetCurrentResourceUsage()
+	cpu := usage[v1.ResourceCPU]
+	if cpu.MilliValue() != 2000 {
+		t.Errorf("Expected 2000m CPU while awake, got %s", cpu.String())
+	}
+
+	ns.Labels[stateLabel] = stateHibernated
+	watcher.updateResourceTracking(ns)
+
+	usage = watcher.GetCurrentResourceUsage()
+	cpu = usage[v1.ResourceCPU]
+	memory := usage[v1.ResourceMemory]
+	if cpu.MilliValue() != 500 {
+		t.Errorf("Expected 500m CPU while hibernated, got %s", cpu.String())
+	}
+	if memory.Value() != 1024*1024*1024 {
+		t.Errorf("Expected 1Gi memory while hibernated, got %s", memory.String())
+	}
+
+	delete(ns.Labels, stateLabel)
+	watcher.updateResourceTracking(ns)
+
+	usage = watcher.GetCurrentResourceUsage()
+	cpu = usage[v1.ResourceCPU]
+	if cpu.MilliValue() != 2000 {
+		t.Errorf("Expected 2000m CPU after waking up, got %s", cpu.String())
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// HookTriggerExpired is used for deletions by the watcher after the lifetime has ended
+	HookTriggerExpired = "expired"
+	// HookTriggerAPI is used for deletions through the REST API
+	HookTriggerAPI = "api"
+
+	defaultHookTimeout = 30 * time.Second
+	// maxHookOutput limits how much output of a failed exec hook is logged
+	maxHookOutput = 1024
+)
+
+// HookEvent describes the namespace a hook is run for
+type HookEvent struct {
+	Trigger   string            `json:"trigger"`
+	Namespace string            `json:"namespace"`
+	Creator   string            `json:"creator,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// Hook is an action that runs before a namespace is deleted
+type Hook interface {
+	Run(ctx context.Context, event HookEvent) error
+}
+
+// ExecHook runs a command with the event as JSON on stdin and in environment variables
+type ExecHook struct {
+	command []string
+}
+
+// Run executes the command and fails on a non-zero exit code
+func (eh *ExecHook) Run(ctx context.Context, event HookEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hook event: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, eh.command[0], eh.command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"TENAMA_TRIGGER="+event.Trigger,
+		"TENAMA_NAMESPACE="+event.Namespace,
+		"TENAMA_CREATOR="+event.Creator,
+		"TENAMA_EXPIRES_AT="+event.ExpiresAt.UTC().Format(time.RFC3339),
+	)
+	cmd.Stdin = bytes.NewReader(payload)
+	// do not wait for child processes that keep the output open after a timeout
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if len(output) > maxHookOutput {
+			output = output[:maxHookOutput]
+		}
+		return fmt.Errorf("command failed: %w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// HTTPHook posts the event as JSON to an HTTP endpoint
+type HTTPHook struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// Run sends the event and fails on non-2xx responses
+func (hh *HTTPHook) Run(ctx context.Context, event HookEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hook event: %w", err)
+	}
+	return postSigned(ctx, hh.client, hh.url, hh.secret, payload)
+}
+
+// configuredHook is a hook with its execution settings
+type configuredHook struct {
+	name     string
+	hook     Hook
+	timeout  time.Duration
+	blocking bool
+}
+
+// HookRunner runs the configured pre-delete hooks
+type HookRunner struct {
+	hooks []configuredHook
+}
+
+// NewHookRunner creates the hooks from their configuration
+func NewHookRunner(cfg []models.Hook) (*HookRunner, error) {
+	hr := &HookRunner{}
+	for i, h := range cfg {
+		name := h.Name
+		if name == "" {
+			name = fmt.Sprintf("hook-%d", i)
+		}
+
+		timeout, err := durationOrDefault(h.Timeout, defaultHookTimeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("hook %s: invalid timeout %q", name, h.Timeout)
+		}
+
+		var hook Hook
+		switch {
+		case len(h.Command) > 0 && h.URL != "":
+			return nil, fmt.Errorf("hook %s: command and url are mutually exclusive", name)
+		case len(h.Command) > 0:
+			hook = &ExecHook{command: h.Command}
+		case h.URL != "":
+			hook = &HTTPHook{url: h.URL, secret: []byte(h.Secret), client: &http.Client{}}
+		default:
+			return nil, fmt.Errorf("hook %s: either command or url is required", name)
+		}
+
+		hr.hooks = append(hr.hooks, configuredHook{name: name, hook: hook, timeout: timeout, blocking: h.Blocking})
+	}
+	return hr, nil
+}
+
+// RunPreDelete runs all hooks for a namespace that is about to be deleted. Blocking
+// hooks run concurrently and are waited for, bounded by their timeouts; the others
+// run in the background. Failures are logged and never prevent the deletion.
+func (hr *HookRunner) RunPreDelete(ns *v1.Namespace, trigger string) {
+	if hr == nil || len(hr.hooks) == 0 {
+		return
+	}
+
+	event := HookEvent{
+		Trigger:   trigger,
+		Namespace: ns.Name,
+		Creator:   ns.Annotations[creatorAnnotation],
+		Labels:    ns.Labels,
+	}
+	if expiresAt, err := namespaceExpiration(ns); err == nil {
+		event.ExpiresAt = expiresAt.UTC()
+	}
+
+	var wg sync.WaitGroup
+	for _, h := range hr.hooks {
+		if !h.blocking {
+			go h.run(event)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.run(event)
+		}()
+	}
+	wg.Wait()
+}
+
+// run executes a single hook within its timeout and logs the outcome
+func (h configuredHook) run(event HookEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := h.hook.Run(ctx, event)
+	duration := time.Since(start).Round(time.Millisecond).String()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		slog.Warn("Pre-delete hook timed out", "hook", h.name, "namespace", event.Namespace, "timeout", h.timeout.String())
+	case err != nil:
+		slog.Error("Pre-delete hook failed", "hook", h.name, "namespace", event.Namespace, "duration", duration, "error", err)
+	default:
+		slog.Info("Pre-delete hook succeeded", "hook", h.name, "namespace", event.Namespace, "duration", duration)
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func hookTestNamespace() *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "tenama-test-1",
+			CreationTimestamp: metav1.NewTime(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)),
+			Labels: map[string]string{
+				"created-by":                "tenama",
+				"tenama/namespace-duration": "24h0m0s",
+			},
+			Annotations: map[string]string{creatorAnnotation: "jane"},
+		},
+	}
+}
+
+func TestNewHookRunnerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		hook models.Hook
+	}{
+		{"neither command nor url", models.Hook{Name: "empty"}},
+		{"command and url", models.Hook{Command: []string{"true"}, URL: "http://localhost"}},
+		{"invalid timeout", models.Hook{Command: []string{"true"}, Timeout: "soon"}},
+		{"negative timeout", models.Hook{Command: []string{"true"}, Timeout: "-1s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHookRunner([]models.Hook{tt.hook}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestExecHook(t *testing.T) {
+	event := HookEvent{Trigger: HookTriggerExpired, Namespace: "tenama-test-1", Creator: "jane"}
+
+	hook := &ExecHook{command: []string{"sh", "-c", `test "$TENAMA_NAMESPACE" = tenama-test-1 && test "$TENAMA_CREATOR" = jane && grep -q '"trigger":"expired"'`}}
+	if err := hook.Run(context.Background(), event); err != nil {
+		t.Errorf("Expected hook to receive the event, got %v", err)
+	}
+
+	failing := &ExecHook{command: []string{"sh", "-c", "echo broken; exit 3"}}
+	if err := failing.Run(context.Background(), event); err == nil {
+		t.Error("Expected error for non-zero exit code")
+	}
+}
+
+// TestRunPreDeleteBounded tests that blocking hooks delay the deletion at most by their timeout
+func TestRunPreDeleteBounded(t *testing.T) {
+	runner, err := NewHookRunner([]models.Hook{
+		{Name: "slow", Command: []string{"sleep", "5"}, Timeout: "100ms", Blocking: true},
+		{Name: "background", Command: []string{"sleep", "5"}, Timeout: "100ms"},
+	})
+	if err != nil {
+		t.Fatalf("NewHookRunner returned error: %v", err)
+	}
+
+	start := time.Now()
+	runner.RunPreDelete(hookTestNamespace(), HookTriggerAPI)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected hooks to be bounded by their timeout, took %s", elapsed)
+	}
+}
+
+// TestWatcherDeleteRunsHooks tests that hooks receive the metadata before the namespace is deleted
+func TestWatcherDeleteRunsHooks(t *testing.T) {
+	ns := hookTestNamespace()
+	clientset := fake.NewSimpleClientset(ns)
+
+	var gotEvent HookEvent
+	var existedDuringHook bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &gotEvent)
+		_, err := clientset.CoreV1().Namespaces().Get(context.Background(), ns.Name, metav1.GetOptions{})
+		existedDuringHook = err == nil
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	runner, err := NewHookRunner([]models.Hook{{Name: "ci", URL: server.URL, Blocking: true}})
+	if err != nil {
+		t.Fatalf("NewHookRunner returned error: %v", err)
+	}
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	watcher.SetPreDeleteHooks(runner)
+
+	watcher.delete(ns.Name)
+
+	if !existedDuringHook {
+		t.Error("Expected namespace to exist while the blocking hook runs")
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), ns.Name, metav1.GetOptions{}); err == nil {
+		t.Error("Expected namespace to be deleted after the hooks")
+	}
+
+	want := HookEvent{
+		Trigger:   HookTriggerExpired,
+		Namespace: ns.Name,
+		Creator:   "jane",
+		ExpiresAt: time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC),
+	}
+	if gotEvent.Trigger != want.Trigger || gotEvent.Namespace != want.Namespace || gotEvent.Creator != want.Creator || !gotEvent.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Errorf("Expected event %+v, got %+v", want, gotEvent)
+	}
+	if gotEvent.Labels["created-by"] != "tenama" {
+		t.Errorf("Expected labels to be passed, got %v", gotEvent.Labels)
+	}
+}
+
+// TestWatcherTerminatingRunsHooksOnce tests that updates of a terminating namespace do not delete it again
+func TestWatcherTerminatingRunsHooksOnce(t *testing.T) {
+	ns := hookTestNamespace()
+	clientset := fake.NewSimpleClientset(ns)
+	// the namespace stays terminating until its finalizers are done
+	clientset.PrependReactor("delete", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj, err := clientset.Tracker().Get(action.GetResource(), "", action.(k8stesting.DeleteAction).GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		terminating := obj.(*v1.Namespace).DeepCopy()
+		terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		return true, nil, clientset.Tracker().Update(action.GetResource(), terminating, "")
+	})
+	watches := newWatchSequence(clientset)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	runner, err := NewHookRunner([]models.Hook{{Name: "ci", URL: server.URL, Blocking: true}})
+	if err != nil {
+		t.Fatalf("NewHookRunner returned error: %v", err)
+	}
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	watcher.SetPreDeleteHooks(runner)
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	defer watcher.Stop()
+
+	w, _ := watches.next(t)
+	terminating, err := clientset.CoreV1().Namespaces().Get(context.Background(), ns.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get namespace: %v", err)
+	}
+	if terminating.DeletionTimestamp == nil {
+		t.Fatal("Expected the expired namespace to be terminating")
+	}
+	w.Modify(terminating)
+	w.Modify(terminating)
+	// events are handled in order, so the timer shows that both updates were processed
+	w.Add(watchTestNamespace("tenama-live", "2"))
+	waitFor(t, func() bool { return watcher.GetActiveTimerCount() == 1 }, "Expected a timer for the live namespace")
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected the hook to run once, ran %d times", got)
+	}
+}
+
+// TestDeleteNamespaceRejectedRunsNoHooks tests that rejected API deletions neither run hooks nor delete
+func TestDeleteNamespaceRejectedRunsNoHooks(t *testing.T) {
+	ns := hookTestNamespace()
+	ns.Name = "other-test-1"
+	clientset := fake.NewSimpleClientset(ns)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	runner, err := NewHookRunner([]models.Hook{{Name: "ci", URL: server.URL, Blocking: true}})
+	if err != nil {
+		t.Fatalf("NewHookRunner returned error: %v", err)
+	}
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	c := &Container{clientset: clientset, config: cfg}
+	c.SetPreDeleteHooks(runner)
+
+	req := httptest.NewRequest(http.MethodDelete, "/namespace/"+ns.Name, nil)
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(req, rec)
+	ctx.SetParamNames("namespace")
+	ctx.SetParamValues(ns.Name)
+
+	if err := c.DeleteNamespace(ctx); err != nil {
+		t.Fatalf("DeleteNamespace returned error: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no hooks to run, got %d calls", calls)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), ns.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected namespace to be kept, got %v", err)
+	}
+}
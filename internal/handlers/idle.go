@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// IdleActionDelete reclaims idle namespaces
+	IdleActionDelete = "delete"
+	// IdleActionWarn only notifies about idle namespaces
+	IdleActionWarn = "warn"
+
+	idleRuleNoRunningPods = "noRunningPods"
+	idleRuleNoPodActivity = "noPodActivity"
+)
+
+// PodGetter is an interface for getting the pod API of a namespace
+type PodGetter interface {
+	Pods(namespace string) corev1.PodInterface
+}
+
+// IdleDetector periodically checks managed namespaces for activity and reclaims
+// or reports namespaces that have been idle for longer than the threshold
+type IdleDetector struct {
+	watcher           *NamespaceWatcher
+	podGetter         PodGetter
+	interval          time.Duration
+	threshold         time.Duration
+	action            string
+	recentPodCreation bool
+
+	mu         sync.Mutex
+	leading    bool                 // whether the watcher led at the last check
+	startedAt  time.Time            // activity before the start or takeover is unknown, so idle periods begin no earlier
+	lastActive map[string]time.Time // last time running pods were observed
+	warned     map[string]bool      // namespaces already reported in the current idle period
+	done       chan struct{}
+}
+
+// NewIdleDetector creates an idle detector that acts through the given watcher.
+// With recentPodCreation enabled, pods created within the threshold count as activity as well.
+func NewIdleDetector(watcher *NamespaceWatcher, podGetter PodGetter, interval time.Duration, threshold time.Duration, action string, recentPodCreation bool) *IdleDetector {
+	return &IdleDetector{
+		watcher:           watcher,
+		podGetter:         podGetter,
+		interval:          interval,
+		threshold:         threshold,
+		action:            action,
+		recentPodCreation: recentPodCreation,
+		leading:           watcher.IsLeading(),
+		lastActive:        make(map[string]time.Time),
+		warned:            make(map[string]bool),
+		done:              make(chan struct{}),
+	}
+}
+
+// Start begins the periodic idle checks
+func (id *IdleDetector) Start(ctx context.Context) {
+	slog.Info("Starting idle detector", "interval", id.interval.String(), "threshold", id.threshold.String(), "action", id.action)
+	id.mu.Lock()
+	id.leading = id.watcher.IsLeading()
+	id.startedAt = time.Now()
+	id.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(id.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-id.done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				id.checkAll(ctx)
+			}
+		}
+	}()
+}
+
+// Stop ends the periodic idle checks
+func (id *IdleDetector) Stop() {
+	slog.Info("Stopping idle detector")
+	close(id.done)
+}
+
+// checkAll runs the idle check for every managed namespace
+func (id *IdleDetector) checkAll(ctx context.Context) {
+	if !id.watcher.IsLeading() {
+		id.mu.Lock()
+		id.leading = false
+		id.mu.Unlock()
+		return
+	}
+
+	id.mu.Lock()
+	if !id.leading {
+		// the activity observed by the previous leader is lost, start over as after a restart
+		id.leading = true
+		id.startedAt = time.Now()
+		id.lastActive = make(map[string]time.Time)
+		id.warned = make(map[string]bool)
+	}
+	id.mu.Unlock()
+
+	list, err := id.watcher.namespaceGetter.Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: "created-by=tenama",
+	})
+	if err != nil {
+		slog.Error("Error listing namespaces for idle detection", "error", err)
+		return
+	}
+
+	seen := make(map[string]bool, len(list.Items))
+	groups := make(map[string][]*v1.Namespace)
+	now := time.Now()
+	for i := range list.Items {
+		ns := &list.Items[i]
+		if !id.watcher.shouldProcess(ns) {
+			continue
+		}
+		seen[ns.Name] = true
+		if group := ns.Labels[groupLabel]; group != "" {
+			groups[group] = append(groups[group], ns)
+			continue
+		}
+		id.check(ctx, []*v1.Namespace{ns}, now)
+	}
+	// the namespaces of a group share their lifecycle and are only reclaimed together
+	for _, members := range groups {
+		id.check(ctx, members, now)
+	}
+
+	// forget namespaces that no longer exist
+	id.mu.Lock()
+	for name := range id.lastActive {
+		if !seen[name] {
+			delete(id.lastActive, name)
+		}
+	}
+	for name := range id.warned {
+		if !seen[name] {
+			delete(id.warned, name)
+		}
+	}
+	id.mu.Unlock()
+}
+
+// check evaluates a namespace, or the namespaces of a group together, and acts on
+// them if they are idle. A group is idle for as long as its least idle namespace.
+func (id *IdleDetector) check(ctx context.Context, members []*v1.Namespace, now time.Time) {
+	var idleFor time.Duration
+	var rule string
+	for i, ns := range members {
+		switch ns.Labels[stateLabel] {
+		case stateExpired:
+			return
+		case stateHibernated:
+			// hibernated namespaces are idle by design and must not be reclaimed right after waking up
+			id.markActive(ns.Name, now)
+			return
+		}
+
+		nsIdleFor, nsRule, err := id.idleDuration(ctx, ns, now)
+		if err != nil {
+			slog.Error("Error checking namespace activity", "namespace", ns.Name, "error", err)
+			return
+		}
+		if i == 0 || nsIdleFor < idleFor {
+			idleFor = nsIdleFor
+		}
+		rule = nsRule
+	}
+	if idleFor < id.threshold {
+		return
+	}
+
+	reason := fmt.Sprintf("no running pods for %s", idleFor.Round(time.Second))
+	if rule == idleRuleNoPodActivity {
+		reason = fmt.Sprintf("no running or newly created pods for %s", idleFor.Round(time.Second))
+	}
+
+	id.mu.Lock()
+	alreadyWarned := true
+	for _, ns := range members {
+		alreadyWarned = alreadyWarned && id.warned[ns.Name]
+		id.warned[ns.Name] = true
+	}
+	id.mu.Unlock()
+
+	if id.action == IdleActionWarn && alreadyWarned {
+		return
+	}
+
+	for _, ns := range members {
+		slog.Info("Namespace is idle", "namespace", ns.Name, "group", ns.Labels[groupLabel], "rule", rule, "reason", reason, "action", id.action)
+
+		expiresAt, _ := namespaceExpiration(ns)
+		id.watcher.notify(NotificationEvent{
+			Type:      EventIdle,
+			Namespace: ns.Name,
+			ExpiresAt: expiresAt.UTC(),
+			Reason:    rule + ": " + reason,
+			Labels:    ns.Labels,
+		})
+
+		if id.action == IdleActionDelete {
+			id.watcher.reclaim(ns.Name, rule)
+		}
+	}
+}
+
+// idleDuration returns for how long a namespace has been idle and the rule that applies
+func (id *IdleDetector) idleDuration(ctx context.Context, ns *v1.Namespace, now time.Time) (time.Duration, string, error) {
+	pods, err := id.podGetter.Pods(ns.Name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	rule := idleRuleNoRunningPods
+	if id.recentPodCreation {
+		rule = idleRuleNoPodActivity
+	}
+
+	// pending pods are about to run and count as activity as well
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == v1.PodRunning || pod.Status.Phase == v1.PodPending {
+			id.markActive(ns.Name, now)
+			return 0, rule, nil
+		}
+	}
+
+	idleSince := ns.CreationTimestamp.Time
+
+	id.mu.Lock()
+	if id.startedAt.After(idleSince) {
+		idleSince = id.startedAt
+	}
+	if lastActive, ok := id.lastActive[ns.Name]; ok && lastActive.After(idleSince) {
+		idleSince = lastActive
+	}
+	id.mu.Unlock()
+
+	if id.recentPodCreation {
+		for _, pod := range pods.Items {
+			if pod.CreationTimestamp.Time.After(idleSince) {
+				idleSince = pod.CreationTimestamp.Time
+			}
+		}
+	}
+
+	return now.Sub(idleSince), rule, nil
+}
+
+// markActive records activity in a namespace and resets its idle period
+func (id *IdleDetector) markActive(namespaceName string, now time.Time) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	id.lastActive[namespaceName] = now
+	delete(id.warned, namespaceName)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func idleTestNamespace(name string, age time.Duration) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			Labels: map[string]string{
+				"created-by":                "tenama",
+				"tenama/namespace-duration": "48h",
+			},
+		},
+	}
+}
+
+func idleTestPod(namespace string, name string, phase v1.PodPhase, age time.Duration) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+// TestIdleDetectorDelete tests that only namespaces idle beyond the threshold are reclaimed
+func TestIdleDetectorDelete(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		idleTestNamespace("tenama-idle", 3*time.Hour),
+		idleTestNamespace("tenama-running", 3*time.Hour),
+		idleTestNamespace("tenama-young", 30*time.Minute),
+		idleTestNamespace("tenama-recent", 3*time.Hour),
+		idleTestPod("tenama-idle", "job", v1.PodSucceeded, 2*time.Hour),
+		idleTestPod("tenama-running", "app", v1.PodRunning, 2*time.Hour),
+		idleTestPod("tenama-recent", "job", v1.PodSucceeded, 10*time.Minute),
+	)
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	detector := NewIdleDetector(watcher, clientset.CoreV1(), time.Minute, time.Hour, IdleActionDelete, true)
+	detector.startedAt = time.Now().Add(-4 * time.Hour)
+
+	detector.checkAll(context.Background())
+
+	tests := []struct {
+		namespace string
+		deleted   bool
+	}{
+		{"tenama-idle", true},
+		{"tenama-running", false},
+		{"tenama-young", false},
+		{"tenama-recent", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			_, err := clientset.CoreV1().Namespaces().Get(context.Background(), tt.namespace, metav1.GetOptions{})
+			if deleted := apierrors.IsNotFound(err); deleted != tt.deleted {
+				t.Errorf("Expected deleted=%v, got %v (err: %v)", tt.deleted, deleted, err)
+			}
+		})
+	}
+
+	watcher.Stop()
+}
+
+// TestIdleDetectorWarnOnce tests that the warn action notifies once per idle period
+func TestIdleDetectorWarnOnce(t *testing.T) {
+	clientset := fake.NewSimpleClientset(idleTestNamespace("tenama-idle", 3*time.Hour))
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	notifier := &recordingNotifier{events: make(chan NotificationEvent, 2)}
+	watcher.SetNotifier(notifier, nil)
+	detector := NewIdleDetector(watcher, clientset.CoreV1(), time.Minute, time.Hour, IdleActionWarn, false)
+	detector.startedAt = time.Now().Add(-4 * time.Hour)
+
+	detector.checkAll(context.Background())
+	detector.checkAll(context.Background())
+
+	select {
+	case event := <-notifier.events:
+		if event.Type != EventIdle || event.Namespace != "tenama-idle" {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected an idle notification")
+	}
+	select {
+	case event := <-notifier.events:
+		t.Errorf("Expected a single notification, got another %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), "tenama-idle", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected namespace to be kept with the warn action: %v", err)
+	}
+
+	watcher.Stop()
+}
+
+// TestIdleDetectorRestart tests that idle periods do not start before the detector
+func TestIdleDetectorRestart(t *testing.T) {
+	clientset := fake.NewSimpleClientset(idleTestNamespace("tenama-idle", 3*time.Hour))
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	detector := NewIdleDetector(watcher, clientset.CoreV1(), time.Minute, time.Hour, IdleActionDelete, false)
+	detector.startedAt = time.Now().Add(-10 * time.Minute)
+
+	detector.checkAll(context.Background())
+
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), "tenama-idle", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected namespace to be kept right after a restart: %v", err)
+	}
+
+	watcher.Stop()
+}
+
+// TestIdleDetectorTakeover tests that a new leader does not reclaim namespaces whose
+// activity was only observed by the previous leader
+func TestIdleDetectorTakeover(t *testing.T) {
+	clientset := fake.NewSimpleClientset(idleTestNamespace("tenama-idle", 3*time.Hour))
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	watcher.SetLeading(context.Background(), false)
+	detector := NewIdleDetector(watcher, clientset.CoreV1(), time.Minute, time.Hour, IdleActionDelete, false)
+	detector.startedAt = time.Now().Add(-4 * time.Hour)
+
+	detector.checkAll(context.Background())
+	watcher.SetLeading(context.Background(), true)
+	detector.checkAll(context.Background())
+
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), "tenama-idle", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected namespace to be kept right after a takeover: %v", err)
+	}
+
+	watcher.Stop()
+}
+
+// TestIdleDetectorGroup tests that the namespaces of a group are only reclaimed together
+func TestIdleDetectorGroup(t *testing.T) {
+	member := func(name string, group string) *v1.Namespace {
+		ns := idleTestNamespace(name, 3*time.Hour)
+		ns.Labels[groupLabel] = group
+		return ns
+	}
+	clientset := fake.NewSimpleClientset(
+		member("tenama-api-idle", "g1"),
+		member("tenama-db-running", "g1"),
+		member("tenama-api-abcde", "g2"),
+		member("tenama-db-fghij", "g2"),
+		idleTestPod("tenama-db-running", "app", v1.PodRunning, 2*time.Hour),
+	)
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	detector := NewIdleDetector(watcher, clientset.CoreV1(), time.Minute, time.Hour, IdleActionDelete, false)
+	detector.startedAt = time.Now().Add(-4 * time.Hour)
+
+	detector.checkAll(context.Background())
+
+	tests := []struct {
+		namespace string
+		deleted   bool
+	}{
+		{"tenama-api-idle", false},
+		{"tenama-db-running", false},
+		{"tenama-api-abcde", true},
+		{"tenama-db-fghij", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			_, err := clientset.CoreV1().Namespaces().Get(context.Background(), tt.namespace, metav1.GetOptions{})
+			if deleted := apierrors.IsNotFound(err); deleted != tt.deleted {
+				t.Errorf("Expected deleted=%v, got %v (err: %v)", tt.deleted, deleted, err)
+			}
+		})
+	}
+
+	watcher.Stop()
+}
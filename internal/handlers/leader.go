@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// NewLeaderElector creates a Lease-based leader elector that hands the namespace
+// cleanup to the watcher while this replica is the leader
+func NewLeaderElector(leases coordinationv1.LeasesGetter, cfg models.LeaderElection, identity string, watcher *NamespaceWatcher) (*leaderelection.LeaderElector, error) {
+	leaseDuration, err := durationOrDefault(cfg.LeaseDuration, 15*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lease duration: %w", err)
+	}
+	renewDeadline, err := durationOrDefault(cfg.RenewDeadline, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid renew deadline: %w", err)
+	}
+	retryPeriod, err := durationOrDefault(cfg.RetryPeriod, 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry period: %w", err)
+	}
+
+	leaseName := cfg.LeaseName
+	if leaseName == "" {
+		leaseName = "tenama"
+	}
+	leaseNamespace := cfg.LeaseNamespace
+	if leaseNamespace == "" {
+		leaseNamespace = "tenama-system"
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      leaseName,
+			Namespace: leaseNamespace,
+		},
+		Client:     leases,
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            leaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				slog.Info("Became leader", "identity", identity)
+				watcher.SetLeading(ctx, true)
+			},
+			OnStoppedLeading: func() {
+				slog.Info("Lost leadership", "identity", identity)
+				watcher.SetLeading(context.Background(), false)
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					slog.Info("New leader elected", "leader", leader)
+				}
+			},
+		},
+	})
+}
+
+// RunLeaderElection campaigns for leadership until ctx is cancelled. A replica that
+// lost its leadership joins the next election.
+func RunLeaderElection(ctx context.Context, elector *leaderelection.LeaderElector) {
+	for {
+		elector.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// durationOrDefault parses value or returns the fallback if value is empty
+func durationOrDefault(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return parseDuration(value)
+}
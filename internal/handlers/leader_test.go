@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestSetLeading tests that only the leader arms cleanup timers while all replicas track resources
+func TestSetLeading(t *testing.T) {
+	ns := watchTestNamespace("tenama-test-1", "1")
+	ns.Labels["tenama/resource-cpu"] = "1"
+	clientset := fake.NewSimpleClientset(ns)
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	defer watcher.Stop()
+
+	watcher.SetLeading(context.Background(), false)
+	if err := watcher.initializeExisting(context.Background()); err != nil {
+		t.Fatalf("initializeExisting returned error: %v", err)
+	}
+	if count := watcher.GetActiveTimerCount(); count != 0 {
+		t.Errorf("Expected no timers on a follower, got %d", count)
+	}
+	if len(watcher.GetCurrentResourceUsage()) == 0 {
+		t.Error("Expected followers to track resources")
+	}
+
+	watcher.SetLeading(context.Background(), true)
+	if count := watcher.GetActiveTimerCount(); count != 1 {
+		t.Errorf("Expected the new leader to arm 1 timer, got %d", count)
+	}
+
+	watcher.SetLeading(context.Background(), false)
+	if count := watcher.GetActiveTimerCount(); count != 0 {
+		t.Errorf("Expected timers to be stopped after losing leadership, got %d", count)
+	}
+	if len(watcher.GetCurrentResourceUsage()) == 0 {
+		t.Error("Expected resource tracking to survive the handover")
+	}
+}
+
+// TestLeaderElection tests that the elected replica takes over the cleanup
+func TestLeaderElection(t *testing.T) {
+	clientset := fake.NewSimpleClientset(watchTestNamespace("tenama-test-1", "1"))
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	defer watcher.Stop()
+	watcher.SetLeading(context.Background(), false)
+
+	elector, err := NewLeaderElector(clientset.CoordinationV1(), models.LeaderElection{
+		LeaseDuration: "1s",
+		RenewDeadline: "500ms",
+		RetryPeriod:   "100ms",
+	}, "replica-1", watcher)
+	if err != nil {
+		t.Fatalf("NewLeaderElector returned error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunLeaderElection(ctx, elector)
+		close(done)
+	}()
+
+	waitFor(t, watcher.IsLeading, "Expected replica to become leader")
+	waitFor(t, func() bool { return watcher.GetActiveTimerCount() == 1 }, "Expected leader to arm the cleanup timer")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected leader election to stop after cancellation")
+	}
+	waitFor(t, func() bool { return !watcher.IsLeading() }, "Expected leadership to be released")
+}
+
+func TestNewLeaderElectorInvalidConfig(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+
+	tests := []struct {
+		name string
+		cfg  models.LeaderElection
+	}{
+		{"invalid duration", models.LeaderElection{LeaseDuration: "soon"}},
+		{"renew deadline exceeds lease duration", models.LeaderElection{LeaseDuration: "5s", RenewDeadline: "10s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewLeaderElector(clientset.CoordinationV1(), tt.cfg, "replica-1", watcher); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
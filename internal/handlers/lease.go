@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const extensionCountLabel = "tenama/extension-count"
+
+// errExtensionNotAllowed is returned when an extension would violate the configured guardrails
+var errExtensionNotAllowed = errors.New("extension not allowed")
+
+// ExtendNamespace - Extends the lifetime of a namespace
+func (c *Container) ExtendNamespace(ctx echo.Context) error {
+	namespace := strings.Trim(ctx.Param("namespace"), "/")
+
+	if !strings.HasPrefix(namespace, c.config.Namespace.Prefix) {
+		slog.Info("Namespace does not start with prefix", "namespace", namespace, "prefix", c.config.Namespace.Prefix)
+		return c.sendErrorResponse(ctx, namespace, "Namespace does not start with prefix "+c.config.Namespace.Prefix, http.StatusBadRequest)
+	}
+
+	if !c.config.Namespace.Extension.Enabled {
+		return c.sendErrorResponse(ctx, namespace, "Namespace extension is disabled", http.StatusForbidden)
+	}
+
+	req := models.NamespaceExtension{}
+	if err := ctx.Bind(&req); err != nil {
+		slog.Error("Error parsing extension request", "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Error parsing extension request", http.StatusBadRequest)
+	}
+
+	extension, err := parseDuration(req.Duration)
+	if err != nil || extension <= 0 {
+		slog.Warn("Error parsing duration", "duration", req.Duration)
+		return c.sendErrorResponse(ctx, namespace, "Error parsing duration", http.StatusBadRequest)
+	}
+
+	policy, err := durationPolicyFor(c.config, authenticatedUser(ctx))
+	if err != nil {
+		slog.Error("Invalid duration policy", "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Invalid duration policy", http.StatusInternalServerError)
+	}
+
+	ns, err := c.clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return c.sendErrorResponse(ctx, namespace, "Namespace not found", http.StatusNotFound)
+		}
+		slog.Error("Error getting namespace", "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Error getting namespace", http.StatusInternalServerError)
+	}
+
+	if ns.Labels["created-by"] != "tenama" {
+		slog.Warn("Namespace is not managed by tenama", "namespace", namespace)
+		return c.sendErrorResponse(ctx, namespace, "Namespace not found", http.StatusNotFound)
+	}
+
+	if ns.Labels[stateLabel] == stateExpired {
+		return c.sendErrorResponse(ctx, namespace, "Namespace has expired, use restore instead", http.StatusConflict)
+	}
+
+	// the namespaces of a group share their expiry
+	if group := ns.Labels[groupLabel]; group != "" {
+		return c.sendErrorResponse(ctx, namespace, "Namespace belongs to group "+group+", extend the group instead", http.StatusConflict)
+	}
+
+	expiresAt, err := extendLease(ns, extension, c.config.Namespace.Extension)
+	if err != nil {
+		slog.Warn("Namespace extension rejected", "namespace", namespace, "error", err)
+		if errors.Is(err, errExtensionNotAllowed) {
+			return c.sendErrorResponse(ctx, namespace, err.Error(), http.StatusForbidden)
+		}
+		return c.sendErrorResponse(ctx, namespace, "Error extending namespace", http.StatusInternalServerError)
+	}
+
+	// repeated extensions must not add up to more than the policy allows
+	if err := policy.check(expiresAt.Sub(ns.CreationTimestamp.Time)); err != nil {
+		slog.Warn("Namespace extension rejected", "namespace", namespace, "error", err)
+		return c.sendErrorResponse(ctx, namespace, err.Error(), http.StatusBadRequest)
+	}
+
+	// The watcher re-arms the cleanup timer when it receives the MODIFIED event
+	ns, err = c.clientset.CoreV1().Namespaces().Update(context.TODO(), ns, metav1.UpdateOptions{})
+	if err != nil {
+		slog.Error("Error updating namespace", "namespace", namespace, "error", err)
+		if apierrors.IsConflict(err) {
+			return c.sendErrorResponse(ctx, namespace, "Namespace was modified concurrently, please retry", http.StatusConflict)
+		}
+		return c.sendErrorResponse(ctx, namespace, "Error extending namespace", http.StatusInternalServerError)
+	}
+
+	extensions, _ := strconv.Atoi(ns.Labels[extensionCountLabel])
+	slog.Info("Extended namespace", "namespace", namespace, "extension", extension.String(), "expiresAt", expiresAt)
+
+	// the tokens of earlier kubeconfigs expire with the previous lifetime
+	kubeconfig, err := c.renewKubeconfig(ctx, ns, expiresAt)
+	if err != nil {
+		slog.Error("Error renewing kubeconfig", "namespace", namespace, "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Namespace extended, but the kubeconfig could not be renewed", http.StatusInternalServerError)
+	}
+
+	return ctx.JSON(http.StatusOK, models.PatchNamespaceExtend200Response{
+		Message:    "Namespace successfully extended",
+		Namespace:  namespace,
+		Duration:   ns.Labels["tenama/namespace-duration"],
+		Extensions: extensions,
+		ExpiresAt:  expiresAt.UTC().Format(time.RFC3339),
+		KubeConfig: kubeconfig,
+	})
+}
+
+// RestoreNamespace - Restores an expired namespace that is still in its grace period
+func (c *Container) RestoreNamespace(ctx echo.Context) error {
+	namespace := strings.Trim(ctx.Param("namespace"), "/")
+
+	if !strings.HasPrefix(namespace, c.config.Namespace.Prefix) {
+		slog.Info("Namespace does not start with prefix", "namespace", namespace, "prefix", c.config.Namespace.Prefix)
+		return c.sendErrorResponse(ctx, namespace, "Namespace does not start with prefix "+c.config.Namespace.Prefix, http.StatusBadRequest)
+	}
+
+	req := models.NamespaceRestore{}
+	if err := ctx.Bind(&req); err != nil {
+		slog.Error("Error parsing restore request", "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Error parsing restore request", http.StatusBadRequest)
+	}
+
+	policy, err := durationPolicyFor(c.config, authenticatedUser(ctx))
+	if err != nil {
+		slog.Error("Invalid duration policy", "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Invalid duration policy", http.StatusInternalServerError)
+	}
+	if req.Duration == "" && policy.def > 0 {
+		req.Duration = policy.def.String()
+	}
+
+	lease, err := parseDuration(req.Duration)
+	if err != nil || lease <= 0 {
+		slog.Warn("Error parsing duration", "duration", req.Duration)
+		return c.sendErrorResponse(ctx, namespace, "Error parsing duration", http.StatusBadRequest)
+	}
+	if err := policy.check(lease); err != nil {
+		slog.Warn("Namespace restore rejected", "namespace", namespace, "error", err)
+		return c.sendErrorResponse(ctx, namespace, err.Error(), http.StatusBadRequest)
+	}
+
+	ns, err := c.clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return c.sendErrorResponse(ctx, namespace, "Namespace not found", http.StatusNotFound)
+		}
+		slog.Error("Error getting namespace", "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Error getting namespace", http.StatusInternalServerError)
+	}
+
+	if ns.Labels["created-by"] != "tenama" {
+		slog.Warn("Namespace is not managed by tenama", "namespace", namespace)
+		return c.sendErrorResponse(ctx, namespace, "Namespace not found", http.StatusNotFound)
+	}
+
+	if ns.Labels[stateLabel] != stateExpired {
+		return c.sendErrorResponse(ctx, namespace, "Namespace is not expired", http.StatusConflict)
+	}
+
+	// the namespaces of a group share their lifecycle
+	if group := ns.Labels[groupLabel]; group != "" {
+		return c.sendErrorResponse(ctx, namespace, "Namespace belongs to group "+group+", restore the group instead", http.StatusConflict)
+	}
+
+	// Grant the new lease first so that the watcher replaces the grace period deletion timer
+	delete(ns.Labels, stateLabel)
+	delete(ns.Annotations, expiredAtAnnotation)
+	expiresAt := grantLease(ns, lease, time.Now())
+
+	ns, err = c.clientset.CoreV1().Namespaces().Update(context.TODO(), ns, metav1.UpdateOptions{})
+	if err != nil {
+		slog.Error("Error updating namespace", "namespace", namespace, "error", err)
+		if apierrors.IsConflict(err) {
+			return c.sendErrorResponse(ctx, namespace, "Namespace was modified concurrently, please retry", http.StatusConflict)
+		}
+		return c.sendErrorResponse(ctx, namespace, "Error restoring namespace", http.StatusInternalServerError)
+	}
+
+	if err := restoreWorkloads(context.TODO(), c.clientset.AppsV1(), namespace); err != nil {
+		slog.Error("Error restoring workloads", "namespace", namespace, "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Namespace restored, but some workloads could not be scaled up", http.StatusInternalServerError)
+	}
+
+	slog.Info("Restored namespace", "namespace", namespace, "lease", lease.String(), "expiresAt", expiresAt)
+
+	// the tokens of earlier kubeconfigs expired with the namespace
+	kubeconfig, err := c.renewKubeconfig(ctx, ns, expiresAt)
+	if err != nil {
+		slog.Error("Error renewing kubeconfig", "namespace", namespace, "error", err)
+		return c.sendErrorResponse(ctx, namespace, "Namespace restored, but the kubeconfig could not be renewed", http.StatusInternalServerError)
+	}
+
+	return ctx.JSON(http.StatusOK, models.PostNamespaceRestore200Response{
+		Message:    "Namespace successfully restored",
+		Namespace:  namespace,
+		Duration:   ns.Labels["tenama/namespace-duration"],
+		ExpiresAt:  expiresAt.UTC().Format(time.RFC3339),
+		KubeConfig: kubeconfig,
+	})
+}
+
+// renewKubeconfig returns a kubeconfig whose token expires at expiresAt. Only the
+// creator and the users of the namespace receive one, for everyone else it is nil.
+func (c *Container) renewKubeconfig(ctx echo.Context, ns *v1.Namespace, expiresAt time.Time) ([]byte, error) {
+	allowed, err := c.isNamespaceUser(ctx, ns)
+	if err != nil || !allowed {
+		return nil, err
+	}
+	return c.namespaceKubeconfig(ctx.Request().Context(), ns.Name, expiresAt)
+}
+
+// grantLease sets the expiry of the namespace to the given lease after now.
+// It returns the new expiration time.
+func grantLease(ns *v1.Namespace, lease time.Duration, now time.Time) time.Time {
+	expiresAt := now.Add(lease).Round(time.Second)
+	setExpiry(ns, ns.CreationTimestamp.Time, expiresAt)
+	return expiresAt
+}
+
+// extendLease moves the expiry of the namespace by the extension and increments
+// its extension counter. It returns the new expiration time or an error wrapping
+// errExtensionNotAllowed if a guardrail would be violated.
+func extendLease(ns *v1.Namespace, extension time.Duration, policy models.Extension) (time.Time, error) {
+	expiresAt, err := namespaceExpiration(ns)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid namespace expiry: %w", err)
+	}
+
+	extensions := 0
+	if count, ok := ns.Labels[extensionCountLabel]; ok {
+		extensions, err = strconv.Atoi(count)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid extension count: %w", err)
+		}
+	}
+
+	if policy.MaxExtensions > 0 && extensions >= policy.MaxExtensions {
+		return time.Time{}, fmt.Errorf("%w: namespace has already been extended %d of %d times", errExtensionNotAllowed, extensions, policy.MaxExtensions)
+	}
+
+	newExpiresAt := expiresAt.Add(extension)
+	if policy.MaxLifetime != "" {
+		maxLifetime, err := parseDuration(policy.MaxLifetime)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid max lifetime: %w", err)
+		}
+		if lifetime := newExpiresAt.Sub(ns.CreationTimestamp.Time); lifetime > maxLifetime {
+			return time.Time{}, fmt.Errorf("%w: total lifetime of %s would exceed the maximum of %s", errExtensionNotAllowed, lifetime.Round(time.Second), maxLifetime)
+		}
+	}
+
+	setExpiry(ns, ns.CreationTimestamp.Time, newExpiresAt)
+	ns.Labels[extensionCountLabel] = strconv.Itoa(extensions + 1)
+
+	return newExpiresAt, nil
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func namespaceContext(method string, namespace string, body string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, "/namespace/"+namespace, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(req, rec)
+	ctx.SetParamNames("namespace")
+	ctx.SetParamValues(namespace)
+	return ctx, rec
+}
+
+func TestExtendLease(t *testing.T) {
+	created := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		labels       map[string]string
+		annotations  map[string]string
+		extension    time.Duration
+		policy       models.Extension
+		wantDuration string
+		wantCount    string
+		wantExpiry   time.Time
+		wantDenied   bool
+		wantErr      bool
+	}{
+		{
+			name:         "first extension",
+			labels:       map[string]string{"tenama/namespace-duration": "1h0m0s"},
+			extension:    30 * time.Minute,
+			policy:       models.Extension{Enabled: true},
+			wantDuration: "1h30m0s",
+			wantCount:    "1",
+			wantExpiry:   created.Add(90 * time.Minute),
+		},
+		{
+			name:         "subsequent extension",
+			labels:       map[string]string{"tenama/namespace-duration": "2h0m0s", extensionCountLabel: "2"},
+			extension:    time.Hour,
+			policy:       models.Extension{Enabled: true, MaxExtensions: 3, MaxLifetime: "3h"},
+			wantDuration: "3h0m0s",
+			wantCount:    "3",
+			wantExpiry:   created.Add(3 * time.Hour),
+		},
+		{
+			name:         "absolute expiry",
+			labels:       map[string]string{"tenama/namespace-duration": "1h0m0s"},
+			annotations:  map[string]string{expiresAtAnnotation: "2025-01-03T12:00:00Z"},
+			extension:    24 * time.Hour,
+			policy:       models.Extension{Enabled: true, MaxLifetime: "3d"},
+			wantDuration: "72h0m0s",
+			wantCount:    "1",
+			wantExpiry:   created.Add(72 * time.Hour),
+		},
+		{
+			name:        "max lifetime exceeded by absolute expiry",
+			labels:      map[string]string{"tenama/namespace-duration": "1h0m0s"},
+			annotations: map[string]string{expiresAtAnnotation: "2025-01-03T12:00:00Z"},
+			extension:   time.Hour,
+			policy:      models.Extension{Enabled: true, MaxLifetime: "2d"},
+			wantDenied:  true,
+		},
+		{
+			name:       "max extensions reached",
+			labels:     map[string]string{"tenama/namespace-duration": "1h0m0s", extensionCountLabel: "3"},
+			extension:  time.Hour,
+			policy:     models.Extension{Enabled: true, MaxExtensions: 3},
+			wantDenied: true,
+		},
+		{
+			name:       "max lifetime exceeded",
+			labels:     map[string]string{"tenama/namespace-duration": "20h0m0s"},
+			extension:  5 * time.Hour,
+			policy:     models.Extension{Enabled: true, MaxLifetime: "24h"},
+			wantDenied: true,
+		},
+		{
+			name:      "invalid duration label",
+			labels:    map[string]string{"tenama/namespace-duration": "forever"},
+			extension: time.Hour,
+			policy:    models.Extension{Enabled: true},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "tenama-test",
+					CreationTimestamp: metav1.NewTime(created),
+					Labels:            tt.labels,
+					Annotations:       tt.annotations,
+				},
+			}
+
+			expiresAt, err := extendLease(ns, tt.extension, tt.policy)
+
+			if tt.wantDenied {
+				if !errors.Is(err, errExtensionNotAllowed) {
+					t.Errorf("Expected errExtensionNotAllowed, got %v", err)
+				}
+				return
+			}
+			if tt.wantErr {
+				if err == nil || errors.Is(err, errExtensionNotAllowed) {
+					t.Errorf("Expected a non-policy error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extendLease returned error: %v", err)
+			}
+
+			if got := ns.Labels["tenama/namespace-duration"]; got != tt.wantDuration {
+				t.Errorf("Expected duration label %s, got %s", tt.wantDuration, got)
+			}
+			if got := ns.Labels[extensionCountLabel]; got != tt.wantCount {
+				t.Errorf("Expected extension count %s, got %s", tt.wantCount, got)
+			}
+			if !expiresAt.Equal(tt.wantExpiry) {
+				t.Errorf("Expected expiry %s, got %s", tt.wantExpiry, expiresAt)
+			}
+			if got := ns.Annotations[expiresAtAnnotation]; got != tt.wantExpiry.Format(time.RFC3339) {
+				t.Errorf("Expected expires-at annotation %s, got %s", tt.wantExpiry.Format(time.RFC3339), got)
+			}
+		})
+	}
+}
+
+func TestGrantLease(t *testing.T) {
+	created := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := created.Add(26 * time.Hour)
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "tenama-test",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels:            map[string]string{"tenama/namespace-duration": "24h0m0s"},
+		},
+	}
+
+	expiresAt := grantLease(ns, 4*time.Hour, now)
+
+	if want := now.Add(4 * time.Hour); !expiresAt.Equal(want) {
+		t.Errorf("Expected expiry %s, got %s", want, expiresAt)
+	}
+	if got := ns.Labels["tenama/namespace-duration"]; got != "30h0m0s" {
+		t.Errorf("Expected duration label 30h0m0s, got %s", got)
+	}
+	if got := ns.Annotations[expiresAtAnnotation]; got != "2025-01-02T18:00:00Z" {
+		t.Errorf("Expected expires-at annotation 2025-01-02T18:00:00Z, got %s", got)
+	}
+}
+
+// TestExtendNamespaceMaxLifetime tests that repeated extensions cannot exceed the policy maximum
+func TestExtendNamespaceMaxLifetime(t *testing.T) {
+	created := time.Now().Add(-3 * time.Hour)
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "tenama-test",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels: map[string]string{
+				"created-by":                "tenama",
+				"tenama/namespace-duration": "4h0m0s",
+			},
+			Annotations: map[string]string{expiresAtAnnotation: created.Add(4 * time.Hour).UTC().Format(time.RFC3339)},
+		},
+	}
+	clientset := fake.NewSimpleClientset(ns)
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	cfg.Namespace.Extension.Enabled = true
+	cfg.Namespace.DurationPolicy.Max = "5h"
+	c := &Container{clientset: clientset, config: cfg}
+
+	// one hour remains, so the extended lease of 3h would pass a check of the remaining time
+	ctx, rec := namespaceContext(http.MethodPatch, "tenama-test", `{"duration":"2h"}`)
+	if err := c.ExtendNamespace(ctx); err != nil {
+		t.Fatalf("ExtendNamespace returned error: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a total lifetime of 6h, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	ctx, rec = namespaceContext(http.MethodPatch, "tenama-test", `{"duration":"1h"}`)
+	if err := c.ExtendNamespace(ctx); err != nil {
+		t.Fatalf("ExtendNamespace returned error: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for a total lifetime of 5h, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestExtendNamespaceKubeconfig tests that only the users of a namespace receive a renewed kubeconfig
+func TestExtendNamespaceKubeconfig(t *testing.T) {
+	ns, rb, view := ownedNamespace()
+	ns.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	ns.Labels["tenama/namespace-duration"] = "2h0m0s"
+	ns.Annotations[expiresAtAnnotation] = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	clientset := fake.NewSimpleClientset(ns, rb, view)
+	var expirations []int64
+	tokenReactor(clientset, &expirations)
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	cfg.Namespace.Extension.Enabled = true
+	c := &Container{clientset: clientset, config: cfg}
+
+	tests := []struct {
+		user           string
+		wantStatus     int
+		wantKubeconfig bool
+	}{
+		{"mallory", http.StatusOK, false},
+		{"john", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		ctx, rec := namespaceContext(http.MethodPatch, ns.Name, `{"duration":"1h"}`)
+		ctx.Set(authUserKey, tt.user)
+		if err := c.ExtendNamespace(ctx); err != nil {
+			t.Fatalf("ExtendNamespace returned error: %v", err)
+		}
+		var response models.PatchNamespaceExtend200Response
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+		if rec.Code != tt.wantStatus || (len(response.KubeConfig) > 0) != tt.wantKubeconfig {
+			t.Errorf("%s: expected status %d and kubeconfig=%v, got %d: %s", tt.user, tt.wantStatus, tt.wantKubeconfig, rec.Code, rec.Body.String())
+		}
+	}
+
+	// a failed renewal is reported instead of leaving the old token to expire unnoticed
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return action.GetSubresource() == "token", nil, errors.New("unavailable")
+	})
+	ctx, rec := namespaceContext(http.MethodPatch, ns.Name, `{"duration":"1h"}`)
+	ctx.Set(authUserKey, "jane")
+	if err := c.ExtendNamespace(ctx); err != nil {
+		t.Fatalf("ExtendNamespace returned error: %v", err)
+	}
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "kubeconfig could not be renewed") {
+		t.Errorf("Expected the failed renewal to be reported, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// limitRangeFor returns the LimitRange of the template or the configured default,
+// nil means that no LimitRange is created
+func (c *Container) limitRangeFor(tmpl *models.NamespaceTemplate) *models.LimitRange {
+	if tmpl != nil && tmpl.LimitRange != nil {
+		return tmpl.LimitRange
+	}
+	if c.config.Namespace.LimitRange.Enabled {
+		return &c.config.Namespace.LimitRange.LimitRange
+	}
+	return nil
+}
+
+// validateLimitRange checks that all quantities are valid and ordered as
+// min <= defaultRequest <= default <= max, as the API server would reject the LimitRange otherwise
+func validateLimitRange(lr *models.LimitRange) error {
+	for _, resourceName := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		bounds := []struct {
+			name string
+			cr   models.ContainerResources
+		}{
+			{"min", lr.Min},
+			{"defaultRequest", lr.DefaultRequest},
+			{"default", lr.Default},
+			{"max", lr.Max},
+		}
+
+		var lowerName string
+		var lower *resource.Quantity
+		for _, bound := range bounds {
+			value := bound.cr.CPU
+			if resourceName == v1.ResourceMemory {
+				value = bound.cr.Memory
+			}
+			if value == "" {
+				continue
+			}
+			q, err := resource.ParseQuantity(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %s %q: %w", bound.name, resourceName, value, err)
+			}
+			if lower != nil && lower.Cmp(q) > 0 {
+				return fmt.Errorf("%s %s %s exceeds %s %s", lowerName, resourceName, lower.String(), bound.name, q.String())
+			}
+			lowerName, lower = bound.name, &q
+		}
+	}
+
+	pvc := lr.PersistentVolumeClaim
+	var min, max resource.Quantity
+	var err error
+	if pvc.Min != "" {
+		if min, err = resource.ParseQuantity(pvc.Min); err != nil {
+			return fmt.Errorf("invalid persistentVolumeClaim min %q: %w", pvc.Min, err)
+		}
+	}
+	if pvc.Max != "" {
+		if max, err = resource.ParseQuantity(pvc.Max); err != nil {
+			return fmt.Errorf("invalid persistentVolumeClaim max %q: %w", pvc.Max, err)
+		}
+		if min.Cmp(max) > 0 {
+			return fmt.Errorf("persistentVolumeClaim min %s exceeds max %s", min.String(), max.String())
+		}
+	}
+	return nil
+}
+
+// storageResourceList converts a set storage bound, invalid ones are rejected by validateLimitRange
+func storageResourceList(value string) v1.ResourceList {
+	q, err := resource.ParseQuantity(value)
+	if value == "" || err != nil {
+		return nil
+	}
+	return v1.ResourceList{v1.ResourceStorage: q}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/Payback159/tenama/internal/models"
+	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestValidateLimitRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		lr      models.LimitRange
+		wantErr bool
+	}{
+		{
+			name: "ordered",
+			lr: models.LimitRange{
+				Min:            models.ContainerResources{CPU: "50m"},
+				DefaultRequest: models.ContainerResources{CPU: "100m", Memory: "128Mi"},
+				Default:        models.ContainerResources{CPU: "500m", Memory: "512Mi"},
+				Max:            models.ContainerResources{CPU: "2", Memory: "2Gi"},
+			},
+		},
+		{
+			name:    "invalid quantity",
+			lr:      models.LimitRange{Default: models.ContainerResources{Memory: "lots"}},
+			wantErr: true,
+		},
+		{
+			name: "request above limit",
+			lr: models.LimitRange{
+				DefaultRequest: models.ContainerResources{CPU: "1"},
+				Default:        models.ContainerResources{CPU: "500m"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "default above max",
+			lr: models.LimitRange{
+				Default: models.ContainerResources{Memory: "4Gi"},
+				Max:     models.ContainerResources{Memory: "2Gi"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "pvc min above max",
+			lr:      models.LimitRange{PersistentVolumeClaim: models.StorageBounds{Min: "10Gi", Max: "1Gi"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLimitRange(&tt.lr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLimitRangeFor(t *testing.T) {
+	cfg := &models.Config{}
+	err := yaml.Unmarshal([]byte(`
+namespace:
+  prefix: tenama
+  limitRange:
+    enabled: true
+    defaultRequest:
+      cpu: 100m
+      memory: 128Mi
+    persistentVolumeClaim:
+      max: 1Gi
+`), cfg)
+	if err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	c := &Container{config: cfg}
+
+	lr := c.craftLimitRangeSpecification("tenama-test-1", c.limitRangeFor(nil))
+	if len(lr.Spec.Limits) != 2 {
+		t.Fatalf("Expected container and pvc limits, got %+v", lr.Spec.Limits)
+	}
+	if q := lr.Spec.Limits[0].DefaultRequest[v1.ResourceCPU]; q.String() != "100m" {
+		t.Errorf("Expected default cpu request of the config, got %s", q.String())
+	}
+	if lr.Spec.Limits[1].Type != v1.LimitTypePersistentVolumeClaim {
+		t.Errorf("Expected pvc limit, got %s", lr.Spec.Limits[1].Type)
+	}
+	if q := lr.Spec.Limits[1].Max[v1.ResourceStorage]; q.String() != "1Gi" {
+		t.Errorf("Expected pvc max of the config, got %s", q.String())
+	}
+
+	tmpl := &models.NamespaceTemplate{Name: "small-ci", LimitRange: &models.LimitRange{Default: models.ContainerResources{CPU: "200m"}}}
+	if got := c.limitRangeFor(tmpl); got != tmpl.LimitRange {
+		t.Errorf("Expected the limit range of the template, got %+v", got)
+	}
+
+	cfg.Namespace.LimitRange.Enabled = false
+	if got := c.limitRangeFor(nil); got != nil {
+		t.Errorf("Expected no limit range when disabled, got %+v", got)
+	}
+}
@@ -8,9 +8,17 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	// authUserKey is the echo context key of the authenticated username
+	authUserKey = "tenama-user"
+	// authGroupsKey is the echo context key of the groups of the authenticated user
+	authGroupsKey = "tenama-groups"
+)
+
 type user struct {
 	username string
 	password string
+	groups   []string
 }
 
 var userList []user
@@ -18,7 +26,7 @@ var userList []user
 func (c *Container) SetBasicAuthUserList(cfg *models.Config) {
 	for _, u := range cfg.BasicAuth {
 		slog.Debug("Adding user to basic auth list", "username", u.Username)
-		userList = append(userList, user{username: u.Username, password: u.Password})
+		userList = append(userList, user{username: u.Username, password: u.Password, groups: u.Groups})
 	}
 }
 
@@ -29,9 +37,23 @@ func (c *Container) BasicAuthValidator(username, password string, e echo.Context
 		slog.Debug("Checking against user from list", "listUser", u.username, "requestUser", username)
 		if subtle.ConstantTimeCompare([]byte(username), []byte(u.username)) == 1 &&
 			subtle.ConstantTimeCompare([]byte(password), []byte(u.password)) == 1 {
+			e.Set(authUserKey, username)
+			e.Set(authGroupsKey, u.groups)
 			return true, nil
 		}
 	}
 	slog.Warn("User not found in basic auth list", "username", username)
 	return false, nil
 }
+
+// authenticatedUser returns the username of the request, or an empty string if it is not authenticated
+func authenticatedUser(e echo.Context) string {
+	username, _ := e.Get(authUserKey).(string)
+	return username
+}
+
+// authenticatedGroups returns the groups of the authenticated user
+func authenticatedGroups(e echo.Context) []string {
+	groups, _ := e.Get(authGroupsKey).([]string)
+	return groups
+}
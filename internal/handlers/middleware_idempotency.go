@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// idempotencyKeyHeader is the request header that identifies retries of the same request
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks responses that were replayed from an earlier request
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	defaultIdempotencyTTL   = 24 * time.Hour
+)
+
+// idempotencyState is the outcome of looking up an idempotency key
+type idempotencyState int
+
+const (
+	// idempotencyNew means the key was unknown and is now reserved for the request
+	idempotencyNew idempotencyState = iota
+	// idempotencyReplay means the request was already answered
+	idempotencyReplay
+	// idempotencyInProgress means the original request has not finished yet
+	idempotencyInProgress
+	// idempotencyMismatch means the key was used for a request with a different body
+	idempotencyMismatch
+)
+
+// idempotencyRecord is the stored result of a request
+type idempotencyRecord struct {
+	fingerprint string
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+	expiresAt   time.Time
+}
+
+// IdempotencyStore keeps the results of successful requests per user and key in
+// memory, so that retries return the original result instead of repeating the request
+type IdempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	records map[string]*idempotencyRecord
+}
+
+// NewIdempotencyStore creates an empty store that keeps results for ttl
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:     ttl,
+		now:     time.Now,
+		records: make(map[string]*idempotencyRecord),
+	}
+}
+
+// begin looks up the key and reserves it if it is unknown. The returned record is
+// only set for replays.
+func (s *IdempotencyStore) begin(key, fingerprint string) (idempotencyState, *idempotencyRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge()
+
+	record, ok := s.records[key]
+	switch {
+	case !ok:
+		s.records[key] = &idempotencyRecord{fingerprint: fingerprint}
+		return idempotencyNew, nil
+	case record.fingerprint != fingerprint:
+		return idempotencyMismatch, nil
+	case !record.done:
+		return idempotencyInProgress, nil
+	default:
+		replay := *record
+		return idempotencyReplay, &replay
+	}
+}
+
+// complete stores a successful result and starts its TTL. Failed requests release
+// the key so that they can be retried.
+func (s *IdempotencyStore) complete(key string, status int, header http.Header, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok {
+		return
+	}
+	if status < 200 || status >= 300 {
+		delete(s.records, key)
+		return
+	}
+	record.done = true
+	record.status = status
+	record.header = header
+	record.body = body
+	record.expiresAt = s.now().Add(s.ttl)
+}
+
+// release frees a reserved key without storing a result
+func (s *IdempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok && !record.done {
+		delete(s.records, key)
+	}
+}
+
+// purge removes all expired results, the caller must hold mu
+func (s *IdempotencyStore) purge() {
+	now := s.now()
+	for key, record := range s.records {
+		if record.done && now.After(record.expiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// responseCapture copies the response body while it is written to the client
+type responseCapture struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (rc *responseCapture) Write(b []byte) (int, error) {
+	rc.body.Write(b)
+	return rc.ResponseWriter.Write(b)
+}
+
+// canonicalBody returns a JSON body with sorted keys and without insignificant whitespace,
+// so that retries that encode the same request differently share a fingerprint. Other
+// bodies are returned unchanged.
+func canonicalBody(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return body
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return canonical
+}
+
+// IdempotencyMiddleware replays the original response for requests that repeat the
+// Idempotency-Key of an earlier successful request of the same user. Reusing a key
+// with a different request is rejected with a conflict.
+func (c *Container) IdempotencyMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		idempotencyKey := ctx.Request().Header.Get(idempotencyKeyHeader)
+		if idempotencyKey == "" {
+			return next(ctx)
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			return c.sendErrorResponse(ctx, "", "Idempotency-Key must be no more than 255 characters", http.StatusBadRequest)
+		}
+
+		body, err := io.ReadAll(ctx.Request().Body)
+		if err != nil {
+			return c.sendErrorResponse(ctx, "", "Error reading request body", http.StatusBadRequest)
+		}
+		ctx.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		// the key is scoped to the user, the fingerprint covers everything that shapes the result
+		user := authenticatedUser(ctx)
+		key := user + "\x00" + idempotencyKey
+		hash := sha256.New()
+		hash.Write([]byte(ctx.Request().Method + " " + ctx.Request().URL.RequestURI() + "\x00"))
+		hash.Write(canonicalBody(body))
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		state, record := c.idempotency.begin(key, fingerprint)
+		switch state {
+		case idempotencyMismatch:
+			slog.Warn("Idempotency key reused with a different request", "user", user, "key", idempotencyKey)
+			return c.sendErrorResponse(ctx, "", "Idempotency-Key was already used for a different request", http.StatusConflict)
+		case idempotencyInProgress:
+			return c.sendErrorResponse(ctx, "", "A request with this Idempotency-Key is still in progress", http.StatusConflict)
+		case idempotencyReplay:
+			slog.Info("Replaying response for idempotency key", "user", user, "key", idempotencyKey)
+			for name, values := range record.header {
+				ctx.Response().Header()[name] = values
+			}
+			ctx.Response().Header().Set(idempotentReplayedHeader, "true")
+			return ctx.Blob(record.status, record.header.Get(echo.HeaderContentType), record.body)
+		}
+
+		// a panic or an error handled by echo must not leave the key reserved
+		completed := false
+		defer func() {
+			if !completed {
+				c.idempotency.release(key)
+			}
+		}()
+
+		capture := &responseCapture{ResponseWriter: ctx.Response().Writer}
+		ctx.Response().Writer = capture
+		if err := next(ctx); err != nil {
+			return err
+		}
+
+		header := http.Header{}
+		for _, name := range []string{echo.HeaderContentType, echo.HeaderLocation} {
+			if value := ctx.Response().Header().Get(name); value != "" {
+				header.Set(name, value)
+			}
+		}
+		c.idempotency.complete(key, ctx.Response().Status, header, capture.body.Bytes())
+		completed = true
+		return nil
+	}
+}
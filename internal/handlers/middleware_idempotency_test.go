@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type idempotencyTestRequest struct {
+	user string
+	key  string
+	body string
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		requests   []idempotencyTestRequest
+		failFirst  bool
+		wantStatus []int
+		wantCalls  int
+		wantSame   bool
+	}{
+		{
+			name:       "replays the original result",
+			requests:   []idempotencyTestRequest{{"jane", "build-42", `{"infix":"ci"}`}, {"jane", "build-42", `{"infix":"ci"}`}},
+			wantStatus: []int{http.StatusOK, http.StatusOK},
+			wantCalls:  1,
+			wantSame:   true,
+		},
+		{
+			name:       "replays a differently formatted body",
+			requests:   []idempotencyTestRequest{{"jane", "build-42", `{"infix":"ci","duration":"1h"}`}, {"jane", "build-42", "{\n  \"duration\": \"1h\",\n  \"infix\": \"ci\"\n}"}},
+			wantStatus: []int{http.StatusOK, http.StatusOK},
+			wantCalls:  1,
+			wantSame:   true,
+		},
+		{
+			name:       "rejects a different body",
+			requests:   []idempotencyTestRequest{{"jane", "build-42", `{"infix":"ci"}`}, {"jane", "build-42", `{"infix":"other"}`}},
+			wantStatus: []int{http.StatusOK, http.StatusConflict},
+			wantCalls:  1,
+		},
+		{
+			name:       "scopes keys per user",
+			requests:   []idempotencyTestRequest{{"jane", "build-42", `{"infix":"ci"}`}, {"john", "build-42", `{"infix":"other"}`}},
+			wantStatus: []int{http.StatusOK, http.StatusOK},
+			wantCalls:  2,
+		},
+		{
+			name:       "retries failed requests",
+			requests:   []idempotencyTestRequest{{"jane", "build-42", `{"infix":"ci"}`}, {"jane", "build-42", `{"infix":"ci"}`}},
+			failFirst:  true,
+			wantStatus: []int{http.StatusInternalServerError, http.StatusOK},
+			wantCalls:  2,
+		},
+		{
+			name:       "ignores requests without key",
+			requests:   []idempotencyTestRequest{{"jane", "", `{"infix":"ci"}`}, {"jane", "", `{"infix":"ci"}`}},
+			wantStatus: []int{http.StatusOK, http.StatusOK},
+			wantCalls:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Container{idempotency: NewIdempotencyStore(time.Hour)}
+			calls := 0
+			handler := c.IdempotencyMiddleware(func(ctx echo.Context) error {
+				calls++
+				if tt.failFirst && calls == 1 {
+					return c.sendErrorResponse(ctx, "", "Error creating namespace", http.StatusInternalServerError)
+				}
+				return c.send200Reponse(ctx, fmt.Sprintf("tenama-ci-%d", calls), "Namespace created")
+			})
+
+			e := echo.New()
+			var bodies []string
+			for i, r := range tt.requests {
+				req := httptest.NewRequest(http.MethodPost, "/namespace", strings.NewReader(r.body))
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				if r.key != "" {
+					req.Header.Set(idempotencyKeyHeader, r.key)
+				}
+				rec := httptest.NewRecorder()
+				ctx := e.NewContext(req, rec)
+				ctx.Set(authUserKey, r.user)
+
+				if err := handler(ctx); err != nil {
+					t.Fatalf("Handler returned error: %v", err)
+				}
+				if rec.Code != tt.wantStatus[i] {
+					t.Errorf("Request %d: expected status %d, got %d", i, tt.wantStatus[i], rec.Code)
+				}
+				bodies = append(bodies, rec.Body.String())
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("Expected handler to be called %d times, got %d", tt.wantCalls, calls)
+			}
+			if tt.wantSame && bodies[0] != bodies[1] {
+				t.Errorf("Expected replayed body %q, got %q", bodies[0], bodies[1])
+			}
+		})
+	}
+}
+
+func TestIdempotencyStoreExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewIdempotencyStore(time.Hour)
+	store.now = func() time.Time { return now }
+
+	if state, _ := store.begin("jane\x00build-42", "a"); state != idempotencyNew {
+		t.Fatalf("Expected new key, got %v", state)
+	}
+	if state, _ := store.begin("jane\x00build-42", "a"); state != idempotencyInProgress {
+		t.Errorf("Expected key to be reserved, got %v", state)
+	}
+
+	store.complete("jane\x00build-42", http.StatusOK, http.Header{}, []byte("{}"))
+	if state, record := store.begin("jane\x00build-42", "a"); state != idempotencyReplay || string(record.body) != "{}" {
+		t.Errorf("Expected replay, got %v", state)
+	}
+
+	now = now.Add(time.Hour + time.Second)
+	if state, _ := store.begin("jane\x00build-42", "b"); state != idempotencyNew {
+		t.Errorf("Expected key to be reusable after its ttl, got %v", state)
+	}
+}
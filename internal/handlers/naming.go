@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Payback159/tenama/internal/models"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// defaultNameTemplate builds names like tenama-infix-abcde
+	defaultNameTemplate = "{prefix}-{infix}-{suffix}"
+	// nameAttempts bounds the names tried when a generated name is already taken
+	nameAttempts = 5
+)
+
+var (
+	namePlaceholder    = regexp.MustCompile(`\{[a-z]+\}`)
+	invalidNameChars   = regexp.MustCompile(`[^a-z0-9-]+`)
+	repeatedSeparators = regexp.MustCompile(`-{2,}`)
+
+	// namePlaceholders are the values a name template may contain: {suffix} is the
+	// requested suffix or a random one, {rand} is always random
+	namePlaceholders = map[string]bool{"prefix": true, "infix": true, "user": true, "suffix": true, "rand": true}
+	// shortenedPlaceholders are cut, longest first, if a name is too long
+	shortenedPlaceholders = []string{"user", "infix"}
+)
+
+// validateNameTemplate checks that a name template starts with the prefix, only contains
+// known placeholders and valid characters, and that its names are unique through a random
+// part. The prefix must be usable as is, since managed namespaces are recognized by it.
+func validateNameTemplate(template string, prefix string) error {
+	if errs := validation.IsDNS1123Label(prefix); prefix != "" && len(errs) > 0 {
+		return fmt.Errorf("prefix %q is not a valid namespace name: %s", prefix, strings.Join(errs, ", "))
+	}
+	if template == "" {
+		return nil
+	}
+	if !strings.HasPrefix(template, "{prefix}") {
+		return errors.New("the template must start with {prefix}")
+	}
+	for _, placeholder := range namePlaceholder.FindAllString(template, -1) {
+		if !namePlaceholders[strings.Trim(placeholder, "{}")] {
+			return fmt.Errorf("unknown placeholder %s", placeholder)
+		}
+	}
+	if literal := namePlaceholder.ReplaceAllString(template, ""); invalidNameChars.MatchString(literal) {
+		return fmt.Errorf("%q contains characters that are not allowed in namespace names", template)
+	}
+	if !strings.Contains(template, "{suffix}") && !strings.Contains(template, "{rand}") {
+		return errors.New("either {suffix} or {rand} is required")
+	}
+	return nil
+}
+
+// nameTemplate returns the configured name template or the default
+func (c *Container) nameTemplate() string {
+	if c.config.Namespace.NameTemplate != "" {
+		return c.config.Namespace.NameTemplate
+	}
+	return defaultNameTemplate
+}
+
+// randomName reports whether the name of a namespace has a random part, so that
+// another name can be tried if it is already taken
+func (c *Container) randomName(ns *models.Namespace) bool {
+	template := c.nameTemplate()
+	return strings.Contains(template, "{rand}") || (strings.Contains(template, "{suffix}") && ns.Suffix == "")
+}
+
+// namespaceName builds the name of a namespace from the name template. Every call
+// generates new random parts.
+func (c *Container) namespaceName(ns *models.Namespace, user string) (string, error) {
+	template := c.nameTemplate()
+	if c.config.Namespace.Prefix == "" {
+		return "", errors.New("prefix is not set in config file")
+	}
+	if ns.Infix == "" && strings.Contains(template, "{infix}") {
+		return "", errors.New("infix is not set in request")
+	}
+
+	suffix := ns.Suffix
+	if suffix == "" {
+		suffix = StringWithCharset(generatedDefaulfSuffixLength, charset)
+	}
+	return renderNamespaceName(template, map[string]string{
+		"prefix": c.config.Namespace.Prefix,
+		"infix":  normalizeNamePart(ns.Infix),
+		"user":   normalizeNamePart(user),
+		"suffix": normalizeNamePart(suffix),
+		"rand":   StringWithCharset(generatedDefaulfSuffixLength, charset),
+	})
+}
+
+// normalizeNamePart lowercases a value and replaces the characters that are not
+// allowed in RFC 1123 labels with separators
+func normalizeNamePart(value string) string {
+	value = invalidNameChars.ReplaceAllString(strings.ToLower(value), separationString)
+	return strings.Trim(repeatedSeparators.ReplaceAllString(value, separationString), separationString)
+}
+
+// renderNamespaceName replaces the placeholders of the template. Names longer than
+// allowed for namespaces are shortened by cutting the user and the infix.
+func renderNamespaceName(template string, values map[string]string) (string, error) {
+	parts := make(map[string]string, len(values))
+	for key, value := range values {
+		parts[key] = value
+	}
+	render := func() string {
+		name := namePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+			return parts[strings.Trim(placeholder, "{}")]
+		})
+		// empty values must not leave double separators behind
+		return strings.Trim(repeatedSeparators.ReplaceAllString(name, separationString), separationString)
+	}
+
+	name := render()
+	for len(name) > validation.DNS1123LabelMaxLength {
+		longest := ""
+		for _, key := range shortenedPlaceholders {
+			if strings.Contains(template, "{"+key+"}") && len(parts[key]) > len(parts[longest]) {
+				longest = key
+			}
+		}
+		if parts[longest] == "" {
+			return "", fmt.Errorf("namespace name %s is longer than %d characters", name, validation.DNS1123LabelMaxLength)
+		}
+		parts[longest] = strings.TrimRight(parts[longest][:len(parts[longest])-1], separationString)
+		name = render()
+	}
+
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid namespace name %s: %s", name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
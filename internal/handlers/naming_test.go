@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestValidateNameTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		prefix   string
+		wantErr  bool
+	}{
+		{"", "tenama", false},
+		{"{prefix}-{user}-{infix}-{rand}", "tenama", false},
+		{"{prefix}-{infix}-{suffix}", "tenama", false},
+		{"{prefix}-{owner}-{rand}", "tenama", true},
+		{"{prefix}_{infix}_{rand}", "tenama", true},
+		{"{prefix}-{infix}", "tenama", true},
+		{"{infix}-{prefix}-{rand}", "tenama", true},
+		{"ci-{prefix}-{rand}", "tenama", true},
+		{"{prefix}-{infix}-{rand}", "Tenama", true},
+		{"{prefix}-{infix}-{rand}", "tenama-", true},
+		{"", "team_a", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix+"/"+tt.template, func(t *testing.T) {
+			if err := validateNameTemplate(tt.template, tt.prefix); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRenderNamespaceName(t *testing.T) {
+	long := strings.Repeat("a", 80)
+	tests := []struct {
+		name     string
+		template string
+		values   map[string]string
+		want     string
+		wantErr  bool
+	}{
+		{"default", defaultNameTemplate, map[string]string{"prefix": "tenama", "infix": "test", "suffix": "abcde"}, "tenama-test-abcde", false},
+		{"empty user", "{prefix}-{user}-{infix}-{rand}", map[string]string{"prefix": "tenama", "infix": "test", "rand": "abcde"}, "tenama-test-abcde", false},
+		{"long infix", defaultNameTemplate, map[string]string{"prefix": "tenama", "infix": long, "suffix": "abcde"}, "tenama-" + long[:50] + "-abcde", false},
+		{"long suffix", defaultNameTemplate, map[string]string{"prefix": "tenama", "infix": "test", "suffix": long}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderNamespaceName(tt.template, tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNamespaceName(t *testing.T) {
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	cfg.Namespace.NameTemplate = "{prefix}-{user}-{infix}-{rand}"
+	c := &Container{config: cfg}
+
+	name, err := c.namespaceName(&models.Namespace{Infix: "Bug_1234"}, "jane@example.com")
+	if err != nil {
+		t.Fatalf("namespaceName returned error: %v", err)
+	}
+	if !strings.HasPrefix(name, "tenama-jane-example-com-bug-1234-") || len(validation.IsDNS1123Label(name)) > 0 {
+		t.Errorf("Expected a valid name of user and infix, got %q", name)
+	}
+
+	long := strings.Repeat("x", 100)
+	name, err = c.namespaceName(&models.Namespace{Infix: long}, long)
+	if err != nil || len(name) > validation.DNS1123LabelMaxLength {
+		t.Errorf("Expected a shortened name, got %q (%v)", name, err)
+	}
+
+	if _, err := c.namespaceName(&models.Namespace{}, "jane"); err == nil {
+		t.Error("Expected error without infix")
+	}
+}
+
+// TestCreateNamespaceRetry tests that a taken random name is replaced by another one
+func TestCreateNamespaceRetry(t *testing.T) {
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	taken := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenama-test-abcde"}}
+	clientset := fake.NewSimpleClientset(taken)
+	c := &Container{clientset: clientset, config: cfg}
+	// the list is outdated, only the API server knows that the name is taken
+	list := &v1.NamespaceList{}
+
+	nsSpec := taken.DeepCopy()
+	if err := c.createNamespace(context.Background(), nsSpec, &models.Namespace{Infix: "test"}, list); err != nil {
+		t.Fatalf("createNamespace returned error: %v", err)
+	}
+	if nsSpec.Name == taken.Name || !strings.HasPrefix(nsSpec.Name, "tenama-test-") {
+		t.Errorf("Expected a new name, got %q", nsSpec.Name)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), nsSpec.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected namespace with the new name, got %v", err)
+	}
+
+	// a requested suffix is kept, so the name cannot be replaced
+	nsSpec = taken.DeepCopy()
+	err := c.createNamespace(context.Background(), nsSpec, &models.Namespace{Infix: "test", Suffix: "abcde"}, list)
+	if !errors.Is(err, errNamespaceExists) || nsSpec.Name != taken.Name {
+		t.Errorf("Expected conflict for the requested name, got %v (%s)", err, nsSpec.Name)
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// requesterLabel holds a hash of the creator, usernames are often no valid label values
+const requesterLabel = "tenama/requester"
+
+// defaultDNSPeer is the cluster DNS of most distributions
+var defaultDNSPeer = models.NetworkPeer{
+	Name:              "dns",
+	NamespaceSelector: map[string]string{"kubernetes.io/metadata.name": "kube-system"},
+	PodSelector:       map[string]string{"k8s-app": "kube-dns"},
+}
+
+// requesterHash returns the value of the requester label for a user
+func requesterHash(username string) string {
+	sum := sha256.Sum256([]byte(username))
+	return hex.EncodeToString(sum[:16])
+}
+
+// validateNetworkPolicy checks the selectors and IP ranges of all configured peers
+func validateNetworkPolicy(cfg models.NetworkPolicyConfig) error {
+	peers := append([]models.NetworkPeer{}, cfg.AllowFrom...)
+	peers = append(peers, cfg.Egress.AllowTo...)
+	if cfg.Egress.DNS != nil {
+		peers = append(peers, *cfg.Egress.DNS)
+	}
+	for i, peer := range peers {
+		name := peer.Name
+		if name == "" {
+			name = fmt.Sprintf("peer-%d", i)
+		}
+		if err := validateNetworkPeer(peer); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateNetworkPeer(peer models.NetworkPeer) error {
+	if peer.CIDR != "" {
+		if len(peer.NamespaceSelector) > 0 || len(peer.PodSelector) > 0 {
+			return errors.New("cidr and selectors are mutually exclusive")
+		}
+		if _, _, err := net.ParseCIDR(peer.CIDR); err != nil {
+			return fmt.Errorf("invalid cidr: %w", err)
+		}
+		return nil
+	}
+	if len(peer.NamespaceSelector) == 0 && len(peer.PodSelector) == 0 {
+		return errors.New("either a selector or a cidr is required")
+	}
+	for _, selector := range []map[string]string{peer.NamespaceSelector, peer.PodSelector} {
+		for key, value := range selector {
+			if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+				return fmt.Errorf("invalid selector key %s: %s", key, strings.Join(errs, ", "))
+			}
+			if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+				return fmt.Errorf("invalid value of selector %s: %s", key, strings.Join(errs, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// networkPolicyPeer converts a configured peer. A peer with only a pod selector
+// selects the pods of all namespaces, not only those of the new namespace.
+func networkPolicyPeer(peer models.NetworkPeer) networkingv1.NetworkPolicyPeer {
+	if peer.CIDR != "" {
+		return networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: peer.CIDR}}
+	}
+	npp := networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{MatchLabels: peer.NamespaceSelector}}
+	if len(peer.PodSelector) > 0 {
+		npp.PodSelector = &metav1.LabelSelector{MatchLabels: peer.PodSelector}
+	}
+	return npp
+}
+
+// craftNetworkPolicySpecification crafts a NetworkPolicy for all pods of the namespace that
+// only admits traffic from the namespace itself, the allowed peers and, if enabled, the
+// other namespaces of the same requester
+func (c *Container) craftNetworkPolicySpecification(namespace string, creator string) *networkingv1.NetworkPolicy {
+	cfg := c.config.Namespace.NetworkPolicy
+
+	own := []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}}
+	if cfg.SameRequester && creator != "" {
+		own = append(own, networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{
+				"created-by":   "tenama",
+				requesterLabel: requesterHash(creator),
+			}},
+		})
+	}
+
+	from := append([]networkingv1.NetworkPolicyPeer{}, own...)
+	for _, peer := range cfg.AllowFrom {
+		from = append(from, networkPolicyPeer(peer))
+	}
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.config.Namespace.Prefix + separationString + "isolation",
+			Namespace: namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{{From: from}},
+		},
+	}
+
+	if cfg.Egress.Restricted {
+		dns := defaultDNSPeer
+		if cfg.Egress.DNS != nil {
+			dns = *cfg.Egress.DNS
+		}
+		udp, tcp := v1.ProtocolUDP, v1.ProtocolTCP
+		port := intstr.FromInt32(53)
+
+		to := append([]networkingv1.NetworkPolicyPeer{}, own...)
+		for _, peer := range cfg.Egress.AllowTo {
+			to = append(to, networkPolicyPeer(peer))
+		}
+		np.Spec.PolicyTypes = append(np.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+		np.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{
+			{
+				To:    []networkingv1.NetworkPolicyPeer{networkPolicyPeer(dns)},
+				Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &port}, {Protocol: &tcp, Port: &port}},
+			},
+			{To: to},
+		}
+	}
+	return np
+}
+
+func (c *Container) createNetworkPolicy(ctx context.Context, np *networkingv1.NetworkPolicy) error {
+	slog.Debug("Creating network policy", "name", np.Name, "namespace", np.Namespace)
+	if _, err := c.clientset.NetworkingV1().NetworkPolicies(np.Namespace).Create(ctx, np, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create network policy: %w", err)
+	}
+	return nil
+}
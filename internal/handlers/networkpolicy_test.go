@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/Payback159/tenama/internal/models"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestValidateNetworkPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		peer    models.NetworkPeer
+		wantErr bool
+	}{
+		{"namespace selector", models.NetworkPeer{NamespaceSelector: map[string]string{"kubernetes.io/metadata.name": "monitoring"}}, false},
+		{"cidr", models.NetworkPeer{CIDR: "10.0.0.0/8"}, false},
+		{"empty", models.NetworkPeer{Name: "nothing"}, true},
+		{"invalid cidr", models.NetworkPeer{CIDR: "10.0.0.0"}, true},
+		{"cidr and selector", models.NetworkPeer{CIDR: "10.0.0.0/8", PodSelector: map[string]string{"app": "x"}}, true},
+		{"invalid selector", models.NetworkPeer{PodSelector: map[string]string{"app": "not valid"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNetworkPolicy(models.NetworkPolicyConfig{Enabled: true, AllowFrom: []models.NetworkPeer{tt.peer}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCraftNetworkPolicySpecification(t *testing.T) {
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	cfg.Namespace.NetworkPolicy = models.NetworkPolicyConfig{
+		Enabled:       true,
+		AllowFrom:     []models.NetworkPeer{{Name: "ingress", NamespaceSelector: map[string]string{"kubernetes.io/metadata.name": "ingress-nginx"}}},
+		SameRequester: true,
+	}
+	c := &Container{config: cfg}
+
+	np := c.craftNetworkPolicySpecification("tenama-test-1", "jane")
+	if len(np.Spec.PolicyTypes) != 1 || np.Spec.PolicyTypes[0] != networkingv1.PolicyTypeIngress {
+		t.Errorf("Expected ingress policy only, got %v", np.Spec.PolicyTypes)
+	}
+	from := np.Spec.Ingress[0].From
+	if len(from) != 3 {
+		t.Fatalf("Expected own namespace, requester and ingress peers, got %+v", from)
+	}
+	if from[0].PodSelector == nil || from[0].NamespaceSelector != nil {
+		t.Errorf("Expected first peer to select the own namespace, got %+v", from[0])
+	}
+	if got := from[1].NamespaceSelector.MatchLabels[requesterLabel]; got != requesterHash("jane") {
+		t.Errorf("Expected requester selector, got %q", got)
+	}
+	if got := from[2].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"]; got != "ingress-nginx" {
+		t.Errorf("Expected ingress controller peer, got %q", got)
+	}
+
+	// without a creator there are no other namespaces of the same requester
+	if from := c.craftNetworkPolicySpecification("tenama-test-1", "").Spec.Ingress[0].From; len(from) != 2 {
+		t.Errorf("Expected no requester peer without creator, got %+v", from)
+	}
+
+	cfg.Namespace.NetworkPolicy.Egress = models.EgressConfig{Restricted: true, AllowTo: []models.NetworkPeer{{CIDR: "10.0.0.0/8"}}}
+	np = c.craftNetworkPolicySpecification("tenama-test-1", "jane")
+	if len(np.Spec.PolicyTypes) != 2 || len(np.Spec.Egress) != 2 {
+		t.Fatalf("Expected restricted egress, got %+v", np.Spec)
+	}
+	dns := np.Spec.Egress[0]
+	if dns.To[0].PodSelector.MatchLabels["k8s-app"] != "kube-dns" || len(dns.Ports) != 2 || dns.Ports[0].Port.IntValue() != 53 {
+		t.Errorf("Expected default DNS rule, got %+v", dns)
+	}
+	if to := np.Spec.Egress[1].To; len(to) != 3 || to[2].IPBlock == nil || to[2].IPBlock.CIDR != "10.0.0.0/8" {
+		t.Errorf("Expected own namespace, requester and cidr peers, got %+v", to)
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// EventExpiryWarning is sent ahead of the scheduled deletion of a namespace
+const EventExpiryWarning = "namespace.expiring"
+
+// EventExpired is sent when a namespace enters the grace period before its deletion
+const EventExpired = "namespace.expired"
+
+// EventIdle is sent when a namespace has been idle for longer than the configured threshold
+const EventIdle = "namespace.idle"
+
+// signatureHeader carries the HMAC-SHA256 signature of the webhook payload
+const signatureHeader = "X-Tenama-Signature"
+
+// NotificationEvent describes a lifecycle event of a managed namespace
+type NotificationEvent struct {
+	Type      string            `json:"type"`
+	Namespace string            `json:"namespace"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+	Remaining string            `json:"remaining,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// Notifier delivers notification events to namespace owners
+type Notifier interface {
+	Notify(ctx context.Context, event NotificationEvent) error
+}
+
+// WebhookNotifier posts notification events as JSON to an HTTP endpoint
+type WebhookNotifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier that signs every payload with the given secret
+func NewWebhookNotifier(url string, secret string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Notify sends the event to the webhook and fails on non-2xx responses
+func (wn *WebhookNotifier) Notify(ctx context.Context, event NotificationEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	return postSigned(ctx, wn.client, wn.url, wn.secret, payload)
+}
+
+// postSigned posts a JSON payload, signed with the secret if one is set, and fails
+// on non-2xx responses
+func postSigned(ctx context.Context, client *http.Client, url string, secret []byte, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(secret) > 0 {
+		req.Header.Set(signatureHeader, "sha256="+signPayload(secret, payload))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signPayload returns the hex encoded HMAC-SHA256 of the payload
+func signPayload(secret []byte, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	secret := "s3cr3t"
+
+	var gotSignature string
+	var gotEvent NotificationEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotSignature = r.Header.Get(signatureHeader)
+		if r.Header.Get(signatureHeader) != "sha256="+signPayload([]byte(secret), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.Unmarshal(body, &gotEvent)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, secret, 5*time.Second)
+	event := NotificationEvent{
+		Type:      EventExpiryWarning,
+		Namespace: "tenama-test",
+		ExpiresAt: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+		Remaining: "1h0m0s",
+	}
+
+	if err := notifier.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	if gotSignature == "" {
+		t.Error("Expected signature header to be set")
+	}
+	if gotEvent.Namespace != event.Namespace || gotEvent.Type != event.Type {
+		t.Errorf("Expected event %+v, got %+v", event, gotEvent)
+	}
+}
+
+func TestWebhookNotifierErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, "", 5*time.Second)
+	if err := notifier.Notify(context.Background(), NotificationEvent{Namespace: "tenama-test"}); err == nil {
+		t.Error("Expected error for non-2xx response")
+	}
+}
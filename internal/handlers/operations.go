@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+const (
+	operationPending    = "pending"
+	operationRunning    = "running"
+	operationSucceeded  = "succeeded"
+	operationFailed     = "failed"
+	operationRolledBack = "rolledBack"
+
+	defaultOperationTTL = time.Hour
+)
+
+// storedOperation is an operation together with the user that started it
+type storedOperation struct {
+	owner     string
+	operation models.Operation
+}
+
+// OperationStore keeps asynchronous operations in memory. Finished operations are
+// forgotten once their TTL has passed, running ones are kept until they finish.
+type OperationStore struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	now        func() time.Time
+	operations map[string]*storedOperation
+}
+
+// NewOperationStore creates an empty store that keeps finished operations for ttl
+func NewOperationStore(ttl time.Duration) *OperationStore {
+	return &OperationStore{
+		ttl:        ttl,
+		now:        time.Now,
+		operations: make(map[string]*storedOperation),
+	}
+}
+
+// Create registers a new pending operation for a namespace and returns its ID
+func (s *OperationStore) Create(owner, namespace string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge()
+
+	id := string(uuid.NewUUID())
+	s.operations[id] = &storedOperation{
+		owner: owner,
+		operation: models.Operation{
+			ID:        id,
+			Status:    operationPending,
+			Namespace: namespace,
+			Steps:     []models.OperationStep{},
+			CreatedAt: s.now().UTC(),
+		},
+	}
+	return id
+}
+
+// Get returns a copy of the operation if it exists and was started by owner
+func (s *OperationStore) Get(id, owner string) (models.Operation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge()
+
+	stored, ok := s.operations[id]
+	if !ok || stored.owner != owner {
+		return models.Operation{}, false
+	}
+	op := stored.operation
+	op.Steps = append([]models.OperationStep{}, op.Steps...)
+	return op, true
+}
+
+// reporter returns a stepReporter that records the step progress of an operation. Once
+// the namespace step has succeeded, the final name of nsSpec is recorded as well.
+func (s *OperationStore) reporter(id string, nsSpec *v1.Namespace) stepReporter {
+	return func(step, status string) {
+		s.update(id, func(op *models.Operation) {
+			op.Status = operationRunning
+			if step == "namespace" && status == operationSucceeded {
+				// a taken random name is replaced while provisioning
+				op.Namespace = nsSpec.Name
+			}
+			for i := range op.Steps {
+				if op.Steps[i].Name == step {
+					op.Steps[i].Status = status
+					return
+				}
+			}
+			op.Steps = append(op.Steps, models.OperationStep{Name: step, Status: status})
+		})
+	}
+}
+
+// finish marks an operation as done and starts its TTL
+func (s *OperationStore) finish(id string, result *provisioningResult, err error) {
+	s.update(id, func(op *models.Operation) {
+		finishedAt := s.now().UTC()
+		expiresAt := finishedAt.Add(s.ttl)
+		op.FinishedAt = &finishedAt
+		op.ExpiresAt = &expiresAt
+		if err != nil {
+			op.Status = operationFailed
+			op.Message = "Error creating namespace: " + err.Error()
+			return
+		}
+		op.Status = operationSucceeded
+		op.Message = "Namespace created"
+		op.Namespace = result.namespace
+		op.KubeConfig = result.kubeconfig
+		op.Bundle = result.bundle
+		op.Cloned = result.cloned
+	})
+}
+
+func (s *OperationStore) update(id string, fn func(op *models.Operation)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.operations[id]; ok {
+		fn(&stored.operation)
+	}
+}
+
+// purge removes all expired operations, the caller must hold mu
+func (s *OperationStore) purge() {
+	now := s.now()
+	for id, stored := range s.operations {
+		if expiresAt := stored.operation.ExpiresAt; expiresAt != nil && now.After(*expiresAt) {
+			delete(s.operations, id)
+		}
+	}
+}
+
+// GetOperation - Reports the progress of an asynchronous namespace creation
+func (c *Container) GetOperation(ctx echo.Context) error {
+	id := ctx.Param("id")
+	op, ok := c.operations.Get(id, authenticatedUser(ctx))
+	if !ok {
+		slog.Debug("Operation not found", "operation", id)
+		return c.sendErrorResponse(ctx, "", "Operation not found", http.StatusNotFound)
+	}
+	return ctx.JSON(http.StatusOK, op)
+}
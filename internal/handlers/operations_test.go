@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestOperationStoreExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewOperationStore(time.Hour)
+	store.now = func() time.Time { return now }
+
+	id := store.Create("jane", "tenama-test-1")
+	if _, ok := store.Get(id, "john"); ok {
+		t.Error("Expected operation to be hidden from other users")
+	}
+
+	// running operations are kept regardless of their age
+	now = now.Add(24 * time.Hour)
+	if _, ok := store.Get(id, "jane"); !ok {
+		t.Fatal("Expected unfinished operation to be kept")
+	}
+
+	store.finish(id, &provisioningResult{kubeconfig: []byte("kubeconfig")}, nil)
+	op, _ := store.Get(id, "jane")
+	if op.Status != operationSucceeded || string(op.KubeConfig) != "kubeconfig" || op.ExpiresAt == nil {
+		t.Errorf("Unexpected finished operation %+v", op)
+	}
+
+	now = now.Add(time.Hour + time.Second)
+	if _, ok := store.Get(id, "jane"); ok {
+		t.Error("Expected finished operation to expire after its ttl")
+	}
+}
+
+func TestOperationStoreReporter(t *testing.T) {
+	store := NewOperationStore(time.Hour)
+	id := store.Create("jane", "tenama-test-1")
+
+	failure := errors.New("boom")
+	nsSpec := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenama-test-1"}}
+	err := runProvisioningSteps(t.Context(), "tenama-test-1", []provisioningStep{
+		{name: "namespace", run: func(ctx context.Context) error {
+			// the generated name was taken
+			nsSpec.Name = "tenama-test-2"
+			return nil
+		}},
+		{name: "resource-quota", run: func(ctx context.Context) error { return failure }},
+		{name: "kubeconfig", run: func(ctx context.Context) error { return nil }},
+	}, store.reporter(id, nsSpec))
+	store.finish(id, nil, err)
+
+	op, _ := store.Get(id, "jane")
+	want := []models.OperationStep{
+		{Name: "namespace", Status: operationRolledBack},
+		{Name: "resource-quota", Status: operationFailed},
+		{Name: "kubeconfig", Status: operationPending},
+	}
+	if len(op.Steps) != len(want) {
+		t.Fatalf("Expected steps %v, got %v", want, op.Steps)
+	}
+	for i := range want {
+		if op.Steps[i] != want[i] {
+			t.Errorf("Expected step %v, got %v", want[i], op.Steps[i])
+		}
+	}
+	if op.Namespace != "tenama-test-2" {
+		t.Errorf("Expected the final name to be recorded, got %q", op.Namespace)
+	}
+	if op.Status != operationFailed || !strings.Contains(op.Message, "step resource-quota failed") {
+		t.Errorf("Expected failed operation naming the step, got %+v", op)
+	}
+}
+
+// TestCreateNamespaceAsync tests that an async request is accepted and its outcome can be queried
+func TestCreateNamespaceAsync(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "rolebindings"}, "tenama-admin", errors.New("not allowed"))
+	})
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	cfg.Namespace.Duration = "1h"
+	c, err := NewContainer(clientset, cfg)
+	if err != nil {
+		t.Fatalf("NewContainer returned error: %v", err)
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/namespace?async=true", strings.NewReader(`{"infix":"test"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	ctx := e.NewContext(req, rec)
+	ctx.Set(authUserKey, "jane")
+
+	if err := c.CreateNamespace(ctx); err != nil {
+		t.Fatalf("CreateNamespace returned error: %v", err)
+	}
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	var accepted models.PostNamespace202Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &accepted); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if rec.Header().Get(echo.HeaderLocation) != "/operations/"+accepted.OperationID {
+		t.Errorf("Expected location of the operation, got %q", rec.Header().Get(echo.HeaderLocation))
+	}
+
+	var op models.Operation
+	waitFor(t, func() bool {
+		op, _ = c.operations.Get(accepted.OperationID, "jane")
+		return op.Status == operationFailed
+	}, "Expected operation to fail")
+	if op.Namespace != accepted.Namespace || op.KubeConfig != nil {
+		t.Errorf("Unexpected operation %+v", op)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/operations/"+accepted.OperationID, nil)
+	rec = httptest.NewRecorder()
+	ctx = e.NewContext(req, rec)
+	ctx.SetParamNames("id")
+	ctx.SetParamValues(accepted.OperationID)
+	ctx.Set(authUserKey, "john")
+	if err := c.GetOperation(ctx); err != nil {
+		t.Fatalf("GetOperation returned error: %v", err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected operations of other users to be hidden, got status %d", rec.Code)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Payback159/tenama/internal/models"
+)
+
+const defaultPodSecurityLevel = "baseline"
+
+var (
+	errInvalidPodSecurityLevel    = errors.New("invalid pod security level")
+	errPodSecurityLevelNotAllowed = errors.New("pod security level not allowed")
+
+	// podSecurityLevels are ordered from the least to the most restrictive level
+	podSecurityLevels = []string{"privileged", "baseline", "restricted"}
+)
+
+// validatePodSecurityConfig checks that all configured levels exist
+func validatePodSecurityConfig(cfg models.PodSecurityConfig) error {
+	levels := append([]string{}, cfg.Allowed...)
+	for _, grant := range cfg.Grants {
+		if grant.Username == "" && grant.Group == "" {
+			return errors.New("grant without username or group")
+		}
+		levels = append(levels, grant.Allowed...)
+	}
+	for _, level := range levels {
+		if !slices.Contains(podSecurityLevels, level) {
+			return fmt.Errorf("%w %q, allowed are %s", errInvalidPodSecurityLevel, level, strings.Join(podSecurityLevels, ", "))
+		}
+	}
+	return nil
+}
+
+// allowedPodSecurityLevels returns the levels a user may enforce, ordered from the least
+// to the most restrictive. The default level of the template is always included.
+func allowedPodSecurityLevels(cfg models.PodSecurityConfig, tmpl *models.NamespaceTemplate, username string, groups []string) []string {
+	allowed := map[string]bool{templatePodSecurity(tmpl): true}
+	for _, level := range cfg.Allowed {
+		allowed[level] = true
+	}
+	for _, grant := range cfg.Grants {
+		if (grant.Username != "" && grant.Username == username) || (grant.Group != "" && slices.Contains(groups, grant.Group)) {
+			for _, level := range grant.Allowed {
+				allowed[level] = true
+			}
+		}
+	}
+
+	var levels []string
+	for _, level := range podSecurityLevels {
+		if allowed[level] {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// checkPodSecurity validates the requested levels. Warn and audit only report
+// violations and accept every level, the enforced level must be allowed.
+func checkPodSecurity(ps *models.PodSecurity, allowed []string) error {
+	if ps == nil {
+		return nil
+	}
+	for _, level := range []string{ps.Enforce, ps.Warn, ps.Audit} {
+		if level != "" && !slices.Contains(podSecurityLevels, level) {
+			return fmt.Errorf("%w %q, valid levels are %s", errInvalidPodSecurityLevel, level, strings.Join(podSecurityLevels, ", "))
+		}
+	}
+	if ps.Enforce != "" && !slices.Contains(allowed, ps.Enforce) {
+		return fmt.Errorf("%w: %s, allowed levels are %s", errPodSecurityLevelNotAllowed, ps.Enforce, strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// podSecurityLabels returns the Pod Security Admission labels of a namespace
+func podSecurityLabels(ps *models.PodSecurity, tmpl *models.NamespaceTemplate, version string) map[string]string {
+	modes := map[string]string{"enforce": templatePodSecurity(tmpl)}
+	if ps != nil {
+		if ps.Enforce != "" {
+			modes["enforce"] = ps.Enforce
+		}
+		if ps.Warn != "" {
+			modes["warn"] = ps.Warn
+		}
+		if ps.Audit != "" {
+			modes["audit"] = ps.Audit
+		}
+	}
+
+	labels := map[string]string{}
+	for mode, level := range modes {
+		labels["pod-security.kubernetes.io/"+mode] = level
+		labels["pod-security.kubernetes.io/"+mode+"-version"] = version
+	}
+	return labels
+}
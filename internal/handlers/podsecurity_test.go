@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAllowedPodSecurityLevels(t *testing.T) {
+	cfg := models.PodSecurityConfig{
+		Allowed: []string{"restricted"},
+		Grants: []models.PodSecurityGrant{
+			{Username: "admin", Allowed: []string{"privileged"}},
+			{Group: "platform", Allowed: []string{"privileged"}},
+		},
+	}
+	tests := []struct {
+		name   string
+		tmpl   *models.NamespaceTemplate
+		user   string
+		groups []string
+		want   []string
+	}{
+		{"default", nil, "jane", nil, []string{"baseline", "restricted"}},
+		{"user grant", nil, "admin", nil, []string{"privileged", "baseline", "restricted"}},
+		{"group grant", nil, "jane", []string{"dev", "platform"}, []string{"privileged", "baseline", "restricted"}},
+		{"template default", &models.NamespaceTemplate{PodSecurity: "restricted"}, "jane", nil, []string{"restricted"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allowedPodSecurityLevels(cfg, tt.tmpl, tt.user, tt.groups); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckPodSecurity(t *testing.T) {
+	allowed := []string{"baseline", "restricted"}
+	tests := []struct {
+		name    string
+		ps      *models.PodSecurity
+		wantErr error
+	}{
+		{"none", nil, nil},
+		{"allowed", &models.PodSecurity{Enforce: "restricted"}, nil},
+		{"warn at any level", &models.PodSecurity{Enforce: "baseline", Warn: "restricted", Audit: "privileged"}, nil},
+		{"not allowed", &models.PodSecurity{Enforce: "privileged"}, errPodSecurityLevelNotAllowed},
+		{"invalid", &models.PodSecurity{Warn: "strict"}, errInvalidPodSecurityLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkPodSecurity(tt.ps, allowed); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPodSecurityLabels(t *testing.T) {
+	got := podSecurityLabels(&models.PodSecurity{Warn: "restricted"}, nil, "v1.35")
+	want := map[string]string{
+		"pod-security.kubernetes.io/enforce":         "baseline",
+		"pod-security.kubernetes.io/enforce-version": "v1.35",
+		"pod-security.kubernetes.io/warn":            "restricted",
+		"pod-security.kubernetes.io/warn-version":    "v1.35",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+// TestCreateNamespacePodSecurityForbidden tests that the rejection lists the allowed levels
+func TestCreateNamespacePodSecurityForbidden(t *testing.T) {
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	c, err := NewContainer(fake.NewSimpleClientset(), cfg)
+	if err != nil {
+		t.Fatalf("NewContainer returned error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/namespace", strings.NewReader(`{"infix":"test","podSecurity":{"enforce":"privileged"}}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(req, rec)
+	ctx.Set(authUserKey, "jane")
+
+	if err := c.CreateNamespace(ctx); err != nil {
+		t.Fatalf("CreateNamespace returned error: %v", err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "allowed levels are: baseline") {
+		t.Errorf("Expected allowed levels in the response, got %s", rec.Body.String())
+	}
+}
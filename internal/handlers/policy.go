@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+)
+
+// errDurationOutOfPolicy is returned when a requested lifetime violates the duration policy
+var errDurationOutOfPolicy = errors.New("duration out of policy")
+
+// durationPolicy bounds the lifetime of a namespace, from its creation or restore to
+// its expiry. Zero values are unbounded.
+type durationPolicy struct {
+	def time.Duration
+	min time.Duration
+	max time.Duration
+}
+
+// durationPolicyFor returns the duration policy of a user. Per-user overrides replace
+// the individual global values they set. Without a policy default the namespace
+// duration from the config is used.
+func durationPolicyFor(cfg *models.Config, username string) (durationPolicy, error) {
+	global := cfg.Namespace.DurationPolicy
+	def, min, max := global.Default, global.Min, global.Max
+	if def == "" {
+		def = cfg.Namespace.Duration
+	}
+
+	for _, override := range global.Users {
+		if override.Username != username {
+			continue
+		}
+		if override.Default != "" {
+			def = override.Default
+		}
+		if override.Min != "" {
+			min = override.Min
+		}
+		if override.Max != "" {
+			max = override.Max
+		}
+	}
+
+	var policy durationPolicy
+	var err error
+	if policy.def, err = durationOrDefault(def, 0); err != nil {
+		return durationPolicy{}, fmt.Errorf("invalid default duration: %w", err)
+	}
+	if policy.min, err = durationOrDefault(min, 0); err != nil {
+		return durationPolicy{}, fmt.Errorf("invalid minimum duration: %w", err)
+	}
+	if policy.max, err = durationOrDefault(max, 0); err != nil {
+		return durationPolicy{}, fmt.Errorf("invalid maximum duration: %w", err)
+	}
+	if policy.max > 0 && policy.min > policy.max {
+		return durationPolicy{}, fmt.Errorf("minimum duration %s exceeds maximum duration %s", formatDuration(policy.min), formatDuration(policy.max))
+	}
+	if policy.def > 0 {
+		if err := policy.check(policy.def); err != nil {
+			return durationPolicy{}, fmt.Errorf("default duration: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// validateDurationPolicies checks the global policy and all per-user overrides
+func validateDurationPolicies(cfg *models.Config) error {
+	if _, err := durationPolicyFor(cfg, ""); err != nil {
+		return err
+	}
+	for _, override := range cfg.Namespace.DurationPolicy.Users {
+		if _, err := durationPolicyFor(cfg, override.Username); err != nil {
+			return fmt.Errorf("user %s: %w", override.Username, err)
+		}
+	}
+	return nil
+}
+
+// check returns an error wrapping errDurationOutOfPolicy if the lifetime lies outside the allowed range
+func (p durationPolicy) check(lifetime time.Duration) error {
+	if (p.min > 0 && lifetime < p.min) || (p.max > 0 && lifetime > p.max) {
+		return fmt.Errorf("%w: requested lifetime of %s is outside the allowed range of %s", errDurationOutOfPolicy, formatDuration(lifetime), p.describeRange())
+	}
+	return nil
+}
+
+// describeRange renders the allowed range for error messages
+func (p durationPolicy) describeRange() string {
+	switch {
+	case p.min > 0 && p.max > 0:
+		return formatDuration(p.min) + " to " + formatDuration(p.max)
+	case p.max > 0:
+		return "up to " + formatDuration(p.max)
+	case p.min > 0:
+		return "at least " + formatDuration(p.min)
+	default:
+		return "any duration"
+	}
+}
+
+// formatDuration renders whole days as e.g. "14d" and everything else like time.Duration
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
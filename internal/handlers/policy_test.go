@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+)
+
+func policyTestConfig() *models.Config {
+	cfg := &models.Config{}
+	cfg.Namespace.Duration = "24h"
+	cfg.Namespace.DurationPolicy = models.DurationPolicy{
+		Min: "1h",
+		Max: "7d",
+		Users: []models.UserDurationPolicy{
+			{Username: "admin", Max: "30d", Default: "3d"},
+		},
+	}
+	return cfg
+}
+
+func TestDurationPolicyFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     durationPolicy
+	}{
+		{"global policy", "jane", durationPolicy{def: 24 * time.Hour, min: time.Hour, max: 7 * 24 * time.Hour}},
+		{"anonymous", "", durationPolicy{def: 24 * time.Hour, min: time.Hour, max: 7 * 24 * time.Hour}},
+		{"user override", "admin", durationPolicy{def: 3 * 24 * time.Hour, min: time.Hour, max: 30 * 24 * time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := durationPolicyFor(policyTestConfig(), tt.username)
+			if err != nil {
+				t.Fatalf("durationPolicyFor returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected policy %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateDurationPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *models.Config)
+	}{
+		{"invalid max", func(cfg *models.Config) { cfg.Namespace.DurationPolicy.Max = "forever" }},
+		{"min above max", func(cfg *models.Config) { cfg.Namespace.DurationPolicy.Min = "8d" }},
+		{"default out of range", func(cfg *models.Config) { cfg.Namespace.Duration = "30m" }},
+		{"invalid user override", func(cfg *models.Config) { cfg.Namespace.DurationPolicy.Users[0].Min = "31d" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := policyTestConfig()
+			tt.modify(cfg)
+			if err := validateDurationPolicies(cfg); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	if err := validateDurationPolicies(policyTestConfig()); err != nil {
+		t.Errorf("Expected valid policy, got %v", err)
+	}
+	if err := validateDurationPolicies(&models.Config{}); err != nil {
+		t.Errorf("Expected empty policy to be valid, got %v", err)
+	}
+}
+
+func TestDurationPolicyCheck(t *testing.T) {
+	policy := durationPolicy{min: time.Hour, max: 14 * 24 * time.Hour}
+
+	tests := []struct {
+		lifetime time.Duration
+		wantErr  bool
+	}{
+		{time.Hour, false},
+		{14 * 24 * time.Hour, false},
+		{59 * time.Minute, true},
+		{15 * 24 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lifetime.String(), func(t *testing.T) {
+			err := policy.check(tt.lifetime)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("check(%s) error = %v, wantErr %v", tt.lifetime, err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, errDurationOutOfPolicy) {
+					t.Errorf("Expected errDurationOutOfPolicy, got %v", err)
+				}
+				if !strings.Contains(err.Error(), "1h0m0s to 14d") {
+					t.Errorf("Expected error to explain the allowed range, got %q", err)
+				}
+			}
+		})
+	}
+
+	if err := (durationPolicy{}).check(365 * 24 * time.Hour); err != nil {
+		t.Errorf("Expected unbounded policy to allow any lifetime, got %v", err)
+	}
+}
@@ -12,6 +12,11 @@ func (c *Container) LivenessProbe(e echo.Context) error {
 	return e.String(http.StatusOK, "OK")
 }
 
+// ReadinessProbe reports not ready while the namespace watcher is out of sync,
+// because global limits cannot be enforced on stale usage data
 func (c *Container) ReadinessProbe(e echo.Context) error {
+	if c.watcher != nil && !c.watcher.HasSynced() {
+		return e.String(http.StatusServiceUnavailable, "namespace watcher not synced")
+	}
 	return e.String(http.StatusOK, "OK")
 }
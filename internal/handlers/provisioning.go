@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rollbackTimeout bounds the time spent on undoing a failed provisioning
+const rollbackTimeout = 30 * time.Second
+
+// errNamespaceExists is returned when a namespace with a matching name already exists
+var errNamespaceExists = errors.New("namespace already exists")
+
+// provisioningStep is a single step of the namespace creation. Undo is called
+// if the step succeeded but a later step failed.
+type provisioningStep struct {
+	name string
+	run  func(ctx context.Context) error
+	undo func(ctx context.Context) error
+}
+
+// provisioningError reports the step that failed and whether the rollback succeeded
+type provisioningError struct {
+	Step        string
+	Err         error
+	RollbackErr error
+}
+
+func (pe *provisioningError) Error() string {
+	msg := fmt.Sprintf("step %s failed: %v", pe.Step, pe.Err)
+	if pe.RollbackErr != nil {
+		msg += fmt.Sprintf(" (rollback failed: %v)", pe.RollbackErr)
+	}
+	return msg
+}
+
+func (pe *provisioningError) Unwrap() error {
+	return pe.Err
+}
+
+// stepReporter is notified whenever a provisioning step changes its status
+type stepReporter func(step, status string)
+
+// runProvisioningSteps runs the steps in order. The first failure stops the chain,
+// undoes all completed steps in reverse order and is returned as a provisioningError.
+// The report function may be nil.
+func runProvisioningSteps(ctx context.Context, namespace string, steps []provisioningStep, report stepReporter) error {
+	if report == nil {
+		report = func(string, string) {}
+	}
+	for _, step := range steps {
+		report(step.name, operationPending)
+	}
+
+	for i, step := range steps {
+		slog.Debug("Running provisioning step", "namespace", namespace, "step", step.name)
+		report(step.name, operationRunning)
+		err := step.run(ctx)
+		if err == nil {
+			report(step.name, operationSucceeded)
+			continue
+		}
+
+		slog.Error("Provisioning step failed, rolling back", "namespace", namespace, "step", step.name, "error", err)
+		report(step.name, operationFailed)
+		rollbackErr := rollback(namespace, steps[:i])
+		if rollbackErr == nil {
+			for _, completed := range steps[:i] {
+				report(completed.name, operationRolledBack)
+			}
+		}
+		return &provisioningError{Step: step.name, Err: err, RollbackErr: rollbackErr}
+	}
+	return nil
+}
+
+// rollback undoes the completed steps in reverse order. It continues on errors and
+// returns all of them.
+func rollback(namespace string, completed []provisioningStep) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(completed) - 1; i >= 0; i-- {
+		step := completed[i]
+		if step.undo == nil {
+			continue
+		}
+		if err := step.undo(ctx); err != nil && !apierrors.IsNotFound(err) {
+			slog.Error("Error rolling back provisioning step", "namespace", namespace, "step", step.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+			continue
+		}
+		slog.Info("Rolled back provisioning step", "namespace", namespace, "step", step.name)
+	}
+	return errors.Join(errs...)
+}
+
+// provisioningErrorStatus maps a provisioning error to the HTTP status of the response
+func provisioningErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errNamespaceExists), apierrors.IsAlreadyExists(err):
+		return http.StatusConflict
+	case apierrors.IsInvalid(err):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// provisioningResult is handed back to the caller of a successful provisioning
+type provisioningResult struct {
+	namespace  string
+	kubeconfig []byte
+	bundle     []models.BundleObjectStatus
+	cloned     []string
+}
+
+// provisionNamespace creates the namespace with all its objects as one unit and
+// returns the kubeconfig of its service account. The objects of clone, which may be
+// nil, are copied last. The progress of the steps is passed to report, which may be nil.
+func (c *Container) provisionNamespace(ctx context.Context, nsSpec *v1.Namespace, req *models.Namespace, clone *cloneSource, namespaceList *v1.NamespaceList, report stepReporter) (*provisioningResult, error) {
+	name := nsSpec.Name
+	// the template was already checked against the user by the handler
+	tmpl := findTemplate(c.config, req.Template)
+	serviceAccountSpec := c.craftServiceAccountSpecification(name)
+	var token string
+	result := &provisioningResult{}
+
+	steps := []provisioningStep{
+		{
+			name: "namespace",
+			run: func(ctx context.Context) error {
+				err := c.createNamespace(ctx, nsSpec, req, namespaceList)
+				// the name changes if the generated one was already taken
+				name = nsSpec.Name
+				serviceAccountSpec.Namespace = name
+				return err
+			},
+			// the objects of all later steps live in the namespace and are removed with it
+			undo: func(ctx context.Context) error {
+				return c.clientset.CoreV1().Namespaces().Delete(ctx, name, metav1.DeleteOptions{})
+			},
+		},
+		{
+			name: "tenama-rolebinding",
+			run: func(ctx context.Context) error {
+				return c.createRolebinding(ctx, c.craftTenamaRoleBinding(name, "tenama"))
+			},
+		},
+		{
+			name: "resource-quota",
+			run: func(ctx context.Context) error {
+				return c.createNamespaceQuota(ctx, c.craftNamespaceQuotaSpecification(name, tmpl, req.Resources))
+			},
+		},
+	}
+	// the LimitRange gives containers without resources the requests and limits the quota demands
+	if limitRange := c.limitRangeFor(tmpl); limitRange != nil {
+		steps = append(steps, provisioningStep{
+			name: "limit-range",
+			run: func(ctx context.Context) error {
+				return c.createLimitRange(ctx, c.craftLimitRangeSpecification(name, limitRange))
+			},
+		})
+	}
+	if c.config.Namespace.NetworkPolicy.Enabled {
+		steps = append(steps, provisioningStep{
+			name: "network-policy",
+			run: func(ctx context.Context) error {
+				return c.createNetworkPolicy(ctx, c.craftNetworkPolicySpecification(name, nsSpec.Annotations[creatorAnnotation]))
+			},
+		})
+	}
+	copyCfg := c.config.Namespace.Copy
+	var pullSecrets []string
+	if len(copyCfg.Secrets) > 0 || len(copyCfg.ConfigMaps) > 0 {
+		steps = append(steps, provisioningStep{
+			name: "copy-objects",
+			run: func(ctx context.Context) error {
+				var err error
+				pullSecrets, err = c.copyObjects(ctx, name)
+				return err
+			},
+		})
+	}
+	steps = append(steps, provisioningStep{
+		name: "service-account",
+		run: func(ctx context.Context) error {
+			if copyCfg.ImagePullSecrets {
+				serviceAccountSpec.ImagePullSecrets = localObjectReferences(pullSecrets)
+			}
+			return c.createServiceAccount(ctx, serviceAccountSpec)
+		},
+	})
+	if copyCfg.ImagePullSecrets && len(copyCfg.Secrets) > 0 {
+		steps = append(steps, provisioningStep{
+			name: "default-service-account",
+			run: func(ctx context.Context) error {
+				if len(pullSecrets) == 0 {
+					return nil
+				}
+				return c.addImagePullSecrets(ctx, name, "default", pullSecrets)
+			},
+		})
+	}
+	steps = append(steps, []provisioningStep{
+		{
+			name: "user-rolebinding",
+			run: func(ctx context.Context) error {
+				for _, rb := range c.craftUserRolebindings(name, req.Users, serviceAccountSpec.Name, templateRole(tmpl)) {
+					if err := c.createRolebinding(ctx, rb); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "service-account-token",
+			run: func(ctx context.Context) error {
+				expiresAt, err := namespaceExpiration(nsSpec)
+				if err != nil {
+					return err
+				}
+				token, err = c.createServiceAccountToken(ctx, name, serviceAccountSpec.Name, expiresAt)
+				return err
+			},
+		},
+		{
+			name: "kubeconfig",
+			run: func(ctx context.Context) error {
+				var err error
+				result.kubeconfig, err = convertKubeconfigToYaml(c.craftKubeconfig(name, serviceAccountSpec.Name, token))
+				return err
+			},
+		},
+	}...)
+	// failed objects of the bundle are reported, but do not fail the namespace
+	if c.bundleEnabled() {
+		steps = append(steps, provisioningStep{
+			name: "bundle",
+			run: func(ctx context.Context) error {
+				result.bundle = c.applyBundle(ctx, c.bundleVars(nsSpec, req))
+				if failed := describeBundleFailures(result.bundle); failed != "" {
+					slog.Warn("Some objects of the manifest bundle could not be created", "namespace", name, "objects", failed)
+				}
+				return nil
+			},
+		})
+	}
+
+	// objects that already exist, e.g. from the bundle or copied from the shared sources, are kept
+	if clone != nil {
+		steps = append(steps, provisioningStep{
+			name: "clone-objects",
+			run: func(ctx context.Context) error {
+				var err error
+				result.cloned, err = c.cloneObjects(ctx, clone, name)
+				return err
+			},
+		})
+	}
+
+	if err := runProvisioningSteps(ctx, name, steps, report); err != nil {
+		return nil, err
+	}
+	result.namespace = name
+	return result, nil
+}
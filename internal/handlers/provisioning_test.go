@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Payback159/tenama/internal/models"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// tokenReactor answers TokenRequests like the API server, which the fake clientset does not.
+// The requested expirations are recorded in expirations.
+func tokenReactor(clientset *fake.Clientset, expirations *[]int64) {
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		tr := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest).DeepCopy()
+		*expirations = append(*expirations, *tr.Spec.ExpirationSeconds)
+		tr.Status.Token = "token"
+		tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(time.Duration(*tr.Spec.ExpirationSeconds) * time.Second))
+		return true, tr, nil
+	})
+}
+
+func TestRunProvisioningSteps(t *testing.T) {
+	var calls []string
+	step := func(name string, err error) provisioningStep {
+		return provisioningStep{
+			name: name,
+			run: func(ctx context.Context) error {
+				calls = append(calls, "run "+name)
+				return err
+			},
+			undo: func(ctx context.Context) error {
+				calls = append(calls, "undo "+name)
+				return nil
+			},
+		}
+	}
+	failure := errors.New("boom")
+
+	err := runProvisioningSteps(context.Background(), "tenama-test", []provisioningStep{
+		step("first", nil),
+		step("second", nil),
+		step("third", failure),
+		step("fourth", nil),
+	}, nil)
+
+	var pe *provisioningError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Expected provisioningError, got %v", err)
+	}
+	if pe.Step != "third" || !errors.Is(err, failure) || pe.RollbackErr != nil {
+		t.Errorf("Unexpected error %+v", pe)
+	}
+	want := []string{"run first", "run second", "run third", "undo second", "undo first"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestRunProvisioningStepsRollbackFailure(t *testing.T) {
+	err := runProvisioningSteps(context.Background(), "tenama-test", []provisioningStep{
+		{
+			name: "namespace",
+			run:  func(ctx context.Context) error { return nil },
+			undo: func(ctx context.Context) error { return errors.New("still there") },
+		},
+		{
+			name: "quota",
+			run:  func(ctx context.Context) error { return errors.New("forbidden") },
+		},
+	}, nil)
+
+	if err == nil || !strings.Contains(err.Error(), "step quota failed") || !strings.Contains(err.Error(), "rollback failed") {
+		t.Errorf("Expected error to name the failed step and the failed rollback, got %v", err)
+	}
+}
+
+// TestProvisionNamespaceRollback tests that a failed step removes the already created namespace
+func TestProvisionNamespaceRollback(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "rolebindings"}, "tenama-admin", errors.New("not allowed"))
+	})
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	c := &Container{clientset: clientset, config: cfg}
+
+	nsSpec := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenama-test-abcde"}}
+	_, err := c.provisionNamespace(context.Background(), nsSpec, &models.Namespace{}, nil, &v1.NamespaceList{}, nil)
+
+	var pe *provisioningError
+	if !errors.As(err, &pe) || pe.Step != "tenama-rolebinding" {
+		t.Fatalf("Expected tenama-rolebinding step to fail, got %v", err)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), nsSpec.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected namespace to be rolled back, got %v", err)
+	}
+}
+
+// TestProvisionNamespaceExisting tests that an existing namespace is neither reused nor deleted
+func TestProvisionNamespaceExisting(t *testing.T) {
+	existing := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenama-test-abcde"}}
+	clientset := fake.NewSimpleClientset(existing)
+	c := &Container{clientset: clientset, config: &models.Config{}}
+
+	list := &v1.NamespaceList{Items: []v1.Namespace{*existing}}
+	_, err := c.provisionNamespace(context.Background(), existing.DeepCopy(), &models.Namespace{Infix: "test", Suffix: "abcde"}, nil, list, nil)
+
+	if provisioningErrorStatus(err) != http.StatusConflict {
+		t.Errorf("Expected conflict, got %v", err)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), existing.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected existing namespace to be kept, got %v", err)
+	}
+}
+
+// TestProvisionNamespaceKubeconfig tests that the kubeconfig holds a token that expires with the namespace
+func TestProvisionNamespaceKubeconfig(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	var expirations []int64
+	tokenReactor(clientset, &expirations)
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	c := &Container{clientset: clientset, config: cfg}
+	c.SetCluster("https://cluster.example.com", []byte("ca"))
+
+	nsSpec := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenama-test-abcde"}}
+	setExpiry(nsSpec, time.Now(), time.Now().Add(2*time.Hour))
+	result, err := c.provisionNamespace(context.Background(), nsSpec, &models.Namespace{Infix: "test", Suffix: "abcde"}, nil, &v1.NamespaceList{}, nil)
+	if err != nil {
+		t.Fatalf("provisionNamespace returned error: %v", err)
+	}
+
+	kubeconfig, err := clientcmd.Load(result.kubeconfig)
+	if err != nil {
+		t.Fatalf("Expected a valid kubeconfig, got %v", err)
+	}
+	current := kubeconfig.Contexts[kubeconfig.CurrentContext]
+	if current == nil || current.Namespace != nsSpec.Name || kubeconfig.AuthInfos[current.AuthInfo].Token != "token" {
+		t.Errorf("Expected the token of the namespace, got %+v", kubeconfig)
+	}
+	if cluster := kubeconfig.Clusters[current.Cluster]; cluster.Server != "https://cluster.example.com" || string(cluster.CertificateAuthorityData) != "ca" {
+		t.Errorf("Expected the configured cluster, got %+v", cluster)
+	}
+	if len(expirations) != 1 || expirations[0] < 7190 || expirations[0] > 7200 {
+		t.Errorf("Expected a token that expires with the namespace, got %v", expirations)
+	}
+
+	// tokens expire after ten minutes at the earliest
+	if _, err := c.createServiceAccountToken(context.Background(), nsSpec.Name, "tenama-sa", time.Now()); err != nil || expirations[1] != 600 {
+		t.Errorf("Expected the minimum expiration, got %v (%v)", expirations, err)
+	}
+}
+
+func TestProvisioningErrorStatus(t *testing.T) {
+	gr := schema.GroupResource{Resource: "namespaces"}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"exists", &provisioningError{Step: "namespace", Err: errNamespaceExists}, http.StatusConflict},
+		{"already exists", &provisioningError{Step: "namespace", Err: apierrors.NewAlreadyExists(gr, "tenama-test")}, http.StatusConflict},
+		{"invalid", &provisioningError{Step: "namespace", Err: apierrors.NewInvalid(schema.GroupKind{Kind: "Namespace"}, "tenama-test", nil)}, http.StatusBadRequest},
+		{"other", &provisioningError{Step: "quota", Err: errors.New("boom")}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := provisioningErrorStatus(tt.err); got != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// validateMaxResources checks the quantities of the per-namespace maxima
+func validateMaxResources(max models.ResourceMaximums) error {
+	for name, value := range map[string]string{"cpu": max.CPU, "memory": max.Memory, "storage": max.Storage} {
+		if value == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(value); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", name, value, err)
+		}
+	}
+	return nil
+}
+
+// namespaceResources returns the resources a namespace is sized with: the requested
+// ones, bounded by the configured maxima, and the requests of the template or the
+// config for all others. It returns nil if neither requests nor defaults are set.
+func (c *Container) namespaceResources(req *models.ResourceRequest, tmpl *models.NamespaceTemplate) (*models.ResourceRequest, error) {
+	defaults := c.config.Namespace.Resources
+	if tmpl != nil && tmpl.Resources != nil {
+		defaults = *tmpl.Resources
+	}
+	resources := models.ResourceRequest{
+		CPU:     defaults.Requests.CPU,
+		Memory:  defaults.Requests.Memory,
+		Storage: defaults.Requests.Storage,
+	}
+
+	if req != nil {
+		max := c.config.Namespace.MaxResources
+		for _, r := range []struct {
+			name      string
+			requested string
+			max       string
+			target    *string
+		}{
+			{"cpu", req.CPU, max.CPU, &resources.CPU},
+			{"memory", req.Memory, max.Memory, &resources.Memory},
+			{"storage", req.Storage, max.Storage, &resources.Storage},
+		} {
+			if r.requested == "" {
+				continue
+			}
+			requested, err := resource.ParseQuantity(r.requested)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", r.name, r.requested)
+			}
+			if requested.Sign() <= 0 {
+				return nil, fmt.Errorf("%s must be greater than zero", r.name)
+			}
+			if r.max != "" && requested.Cmp(resource.MustParse(r.max)) > 0 {
+				return nil, fmt.Errorf("%s %s exceeds the maximum of %s per namespace", r.name, r.requested, r.max)
+			}
+			*r.target = r.requested
+		}
+	}
+
+	if resources == (models.ResourceRequest{}) {
+		return nil, nil
+	}
+	return &resources, nil
+}
+
+// applyRequestedQuota sets the request quota of a resource to the requested quantity.
+// A limit quota below it is raised, otherwise the requested amount could not be used.
+func applyRequestedQuota(hard v1.ResourceList, requestName v1.ResourceName, limitName v1.ResourceName, value string) {
+	if value == "" {
+		return
+	}
+	requested, err := resource.ParseQuantity(value)
+	if err != nil {
+		return
+	}
+	hard[requestName] = requested
+	if limit, ok := hard[limitName]; ok && limit.Cmp(requested) < 0 {
+		hard[limitName] = requested
+	}
+}
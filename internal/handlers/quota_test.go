@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Payback159/tenama/internal/models"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestNamespaceResources(t *testing.T) {
+	cfg := &models.Config{}
+	cfg.Namespace.Resources.Requests.CPU = "1"
+	cfg.Namespace.Resources.Requests.Memory = "1Gi"
+	cfg.Namespace.MaxResources = models.ResourceMaximums{CPU: "4", Memory: "8Gi"}
+	tmpl := &models.NamespaceTemplate{Name: "small-ci", Resources: &models.Resources{}}
+	tmpl.Resources.Requests.CPU = "500m"
+	c := &Container{config: cfg}
+
+	tests := []struct {
+		name    string
+		req     *models.ResourceRequest
+		tmpl    *models.NamespaceTemplate
+		want    *models.ResourceRequest
+		wantErr bool
+	}{
+		{"defaults", nil, nil, &models.ResourceRequest{CPU: "1", Memory: "1Gi"}, false},
+		{"template defaults", nil, tmpl, &models.ResourceRequest{CPU: "500m"}, false},
+		{"requested", &models.ResourceRequest{CPU: "100m", Storage: "5Gi"}, nil, &models.ResourceRequest{CPU: "100m", Memory: "1Gi", Storage: "5Gi"}, false},
+		{"at the maximum", &models.ResourceRequest{Memory: "8Gi"}, nil, &models.ResourceRequest{CPU: "1", Memory: "8Gi"}, false},
+		{"above the maximum", &models.ResourceRequest{CPU: "4001m"}, nil, nil, true},
+		{"invalid", &models.ResourceRequest{Memory: "lots"}, nil, nil, true},
+		{"zero", &models.ResourceRequest{CPU: "0"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.namespaceResources(tt.req, tt.tmpl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	if got, err := (&Container{config: &models.Config{}}).namespaceResources(nil, nil); got != nil || err != nil {
+		t.Errorf("Expected no resources without requests and defaults, got %+v (%v)", got, err)
+	}
+}
+
+// TestCraftNamespaceQuotaRequested tests that the requested resources size the quota
+func TestCraftNamespaceQuotaRequested(t *testing.T) {
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	cfg.Namespace.Resources.Requests.CPU = "1"
+	cfg.Namespace.Resources.Requests.Memory = "1Gi"
+	cfg.Namespace.Resources.Limits.CPU = "2"
+	cfg.Namespace.Resources.Limits.Memory = "2Gi"
+	c := &Container{config: cfg}
+
+	quota := c.craftNamespaceQuotaSpecification("tenama-test-abcde", nil, &models.ResourceRequest{CPU: "100m", Memory: "4Gi", Storage: "5Gi"})
+	want := v1.ResourceList{
+		v1.ResourceRequestsCPU:     resource.MustParse("100m"),
+		v1.ResourceLimitsCPU:       resource.MustParse("2"),
+		v1.ResourceRequestsMemory:  resource.MustParse("4Gi"),
+		v1.ResourceLimitsMemory:    resource.MustParse("4Gi"),
+		v1.ResourceRequestsStorage: resource.MustParse("5Gi"),
+	}
+	if len(quota.Spec.Hard) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, quota.Spec.Hard)
+	}
+	for name, q := range want {
+		if got := quota.Spec.Hard[name]; got.Cmp(q) != 0 {
+			t.Errorf("Expected %s to be %s, got %s", name, q.String(), got.String())
+		}
+	}
+}
+
+func TestValidateMaxResources(t *testing.T) {
+	if err := validateMaxResources(models.ResourceMaximums{CPU: "4", Memory: "8Gi"}); err != nil {
+		t.Errorf("Expected valid maxima, got %v", err)
+	}
+	if err := validateMaxResources(models.ResourceMaximums{Storage: "much"}); err == nil {
+		t.Error("Expected error for invalid quantity")
+	}
+}
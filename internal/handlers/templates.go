@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// templateLabel records the template a namespace was created from
+const templateLabel = "tenama/template"
+
+var (
+	errTemplateNotFound   = errors.New("template not found")
+	errTemplateNotAllowed = errors.New("template not allowed")
+)
+
+// findTemplate returns the configured template with the given name or nil
+func findTemplate(cfg *models.Config, name string) *models.NamespaceTemplate {
+	for i := range cfg.Namespace.Templates {
+		if cfg.Namespace.Templates[i].Name == name {
+			return &cfg.Namespace.Templates[i]
+		}
+	}
+	return nil
+}
+
+// templateAllowed reports whether a user may select the template
+func templateAllowed(tmpl *models.NamespaceTemplate, username string) bool {
+	return len(tmpl.Users) == 0 || slices.Contains(tmpl.Users, username)
+}
+
+// templateFor resolves the template of a request. An empty name selects no template.
+func (c *Container) templateFor(name, username string) (*models.NamespaceTemplate, error) {
+	if name == "" {
+		return nil, nil
+	}
+	tmpl := findTemplate(c.config, name)
+	if tmpl == nil {
+		return nil, fmt.Errorf("%w: %s", errTemplateNotFound, name)
+	}
+	if !templateAllowed(tmpl, username) {
+		return nil, fmt.Errorf("%w: %s", errTemplateNotAllowed, name)
+	}
+	return tmpl, nil
+}
+
+// templatePodSecurity returns the enforced Pod Security Standard level of a template
+func templatePodSecurity(tmpl *models.NamespaceTemplate) string {
+	if tmpl == nil || tmpl.PodSecurity == "" {
+		return defaultPodSecurityLevel
+	}
+	return tmpl.PodSecurity
+}
+
+// templateRole returns the ClusterRole the requested users are bound to
+func templateRole(tmpl *models.NamespaceTemplate) string {
+	if tmpl == nil || tmpl.Role == "" {
+		return role
+	}
+	return tmpl.Role
+}
+
+// validateTemplates checks the configured templates so that invalid values are
+// reported on startup instead of being dropped on creation
+func validateTemplates(cfg *models.Config) error {
+	seen := map[string]bool{}
+	for _, tmpl := range cfg.Namespace.Templates {
+		if tmpl.Name == "" {
+			return errors.New("template without name")
+		}
+		if seen[tmpl.Name] {
+			return fmt.Errorf("duplicate template %s", tmpl.Name)
+		}
+		seen[tmpl.Name] = true
+
+		if err := validateTemplate(tmpl); err != nil {
+			return fmt.Errorf("template %s: %w", tmpl.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateTemplate(tmpl models.NamespaceTemplate) error {
+	if errs := validation.IsValidLabelValue(tmpl.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid name: %s", strings.Join(errs, ", "))
+	}
+	if tmpl.PodSecurity != "" && !slices.Contains(podSecurityLevels, tmpl.PodSecurity) {
+		return fmt.Errorf("invalid pod security level %q, allowed are %s", tmpl.PodSecurity, strings.Join(podSecurityLevels, ", "))
+	}
+	if tmpl.Duration != "" {
+		if _, err := parseDuration(tmpl.Duration); err != nil {
+			return fmt.Errorf("invalid duration: %w", err)
+		}
+	}
+	for key, value := range tmpl.Labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid label %s: %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid value of label %s: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	for key := range tmpl.Annotations {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid annotation %s: %s", key, strings.Join(errs, ", "))
+		}
+	}
+
+	if r := tmpl.Resources; r != nil {
+		for _, q := range []string{r.Requests.CPU, r.Requests.Memory, r.Requests.Storage, r.Limits.CPU, r.Limits.Memory} {
+			if q == "" {
+				continue
+			}
+			if _, err := resource.ParseQuantity(q); err != nil {
+				return fmt.Errorf("invalid quantity %q: %w", q, err)
+			}
+		}
+	}
+	if tmpl.LimitRange != nil {
+		if err := validateLimitRange(tmpl.LimitRange); err != nil {
+			return fmt.Errorf("invalid limit range: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetTemplates - Lists the templates the user is allowed to select
+func (c *Container) GetTemplates(ctx echo.Context) error {
+	username := authenticatedUser(ctx)
+	templates := []models.Template{}
+	for i := range c.config.Namespace.Templates {
+		tmpl := &c.config.Namespace.Templates[i]
+		if !templateAllowed(tmpl, username) {
+			continue
+		}
+
+		quota := map[string]string{}
+		for name, q := range c.craftNamespaceQuotaSpecification("", tmpl, nil).Spec.Hard {
+			quota[string(name)] = q.String()
+		}
+		templates = append(templates, models.Template{
+			Name:        tmpl.Name,
+			Description: tmpl.Description,
+			PodSecurity: templatePodSecurity(tmpl),
+			Role:        templateRole(tmpl),
+			Duration:    tmpl.Duration,
+			Quota:       quota,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, models.GetTemplates200Response{
+		Message:   "Templates successfully retrieved",
+		Templates: templates,
+	})
+}
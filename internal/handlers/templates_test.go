@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func templateTestConfig() *models.Config {
+	cfg := &models.Config{}
+	cfg.Namespace.Prefix = "tenama"
+	cfg.Namespace.Resources.Requests.CPU = "1"
+	small := models.NamespaceTemplate{
+		Name:        "small-ci",
+		Description: "Small namespace for CI jobs",
+		Resources:   &models.Resources{},
+		LimitRange: &models.LimitRange{
+			Default:        models.ContainerResources{CPU: "200m", Memory: "256Mi"},
+			DefaultRequest: models.ContainerResources{CPU: "100m", Memory: "128Mi"},
+		},
+		Labels:      map[string]string{"team": "ci", "created-by": "someone"},
+		Annotations: map[string]string{"example.com/owner": "ci"},
+		PodSecurity: "restricted",
+		Role:        "admin",
+		Duration:    "2h",
+	}
+	small.Resources.Requests.CPU = "500m"
+	cfg.Namespace.Templates = []models.NamespaceTemplate{
+		small,
+		{Name: "large-perf", Users: []string{"perf"}},
+	}
+	return cfg
+}
+
+func TestValidateTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		template models.NamespaceTemplate
+	}{
+		{"missing name", models.NamespaceTemplate{}},
+		{"invalid name", models.NamespaceTemplate{Name: "small ci"}},
+		{"invalid pod security level", models.NamespaceTemplate{Name: "small", PodSecurity: "strict"}},
+		{"invalid duration", models.NamespaceTemplate{Name: "small", Duration: "soon"}},
+		{"invalid label", models.NamespaceTemplate{Name: "small", Labels: map[string]string{"team name": "ci"}}},
+		{"invalid quantity", models.NamespaceTemplate{Name: "small", LimitRange: &models.LimitRange{Max: models.ContainerResources{CPU: "lots"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &models.Config{}
+			cfg.Namespace.Templates = []models.NamespaceTemplate{tt.template}
+			if err := validateTemplates(cfg); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	if err := validateTemplates(templateTestConfig()); err != nil {
+		t.Errorf("Expected valid templates, got %v", err)
+	}
+	cfg := templateTestConfig()
+	cfg.Namespace.Templates = append(cfg.Namespace.Templates, models.NamespaceTemplate{Name: "small-ci"})
+	if err := validateTemplates(cfg); err == nil {
+		t.Error("Expected an error for duplicate templates")
+	}
+}
+
+func TestTemplateFor(t *testing.T) {
+	c := &Container{config: templateTestConfig()}
+	tests := []struct {
+		name     string
+		template string
+		user     string
+		wantErr  error
+	}{
+		{"no template", "", "jane", nil},
+		{"open template", "small-ci", "jane", nil},
+		{"restricted template", "large-perf", "perf", nil},
+		{"not allowed", "large-perf", "jane", errTemplateNotAllowed},
+		{"unknown", "medium", "jane", errTemplateNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := c.templateFor(tt.template, tt.user)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && tt.template != "" && tmpl.Name != tt.template {
+				t.Errorf("Expected template %s, got %v", tt.template, tmpl)
+			}
+		})
+	}
+}
+
+func TestCraftNamespaceSpecificationTemplate(t *testing.T) {
+	cfg := templateTestConfig()
+	c := &Container{clientset: fake.NewSimpleClientset(), config: cfg}
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/namespace", nil), httptest.NewRecorder())
+
+	nsSpec, err := c.craftNamespaceSpecification(&models.Namespace{Infix: "test", Duration: "1h"}, &cfg.Namespace.Templates[0], ctx)
+	if err != nil {
+		t.Fatalf("craftNamespaceSpecification returned error: %v", err)
+	}
+
+	want := map[string]string{
+		"team":                               "ci",
+		"created-by":                         "tenama",
+		templateLabel:                        "small-ci",
+		"pod-security.kubernetes.io/enforce": "restricted",
+	}
+	for key, value := range want {
+		if nsSpec.Labels[key] != value {
+			t.Errorf("Expected label %s=%s, got %q", key, value, nsSpec.Labels[key])
+		}
+	}
+	if nsSpec.Annotations["example.com/owner"] != "ci" {
+		t.Errorf("Expected template annotation, got %v", nsSpec.Annotations)
+	}
+}
+
+// TestCraftNamespaceSpecificationErrors tests that failures are returned instead of being written to the response
+// and that an unknown server version falls back to the latest Pod Security Standards
+func TestCraftNamespaceSpecificationErrors(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	cfg := templateTestConfig()
+	c := &Container{clientset: clientset, config: cfg}
+
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/namespace", nil), rec)
+	nsSpec, err := c.craftNamespaceSpecification(&models.Namespace{Infix: "test", Duration: "soon"}, nil, ctx)
+	var he *echo.HTTPError
+	if nsSpec != nil || !errors.As(err, &he) || he.Code != http.StatusBadRequest {
+		t.Errorf("Expected a 400 error for an invalid duration, got %v", err)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("Expected no response to be written, got %s", rec.Body.String())
+	}
+
+	clientset.PrependReactor("get", "version", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("unavailable")
+	})
+	nsSpec, err = c.craftNamespaceSpecification(&models.Namespace{Infix: "test", Duration: "1h"}, nil, ctx)
+	if err != nil {
+		t.Fatalf("Expected an unknown server version to fall back to latest, got %v", err)
+	}
+	if got := nsSpec.Labels["pod-security.kubernetes.io/enforce-version"]; got != "latest" {
+		t.Errorf("Expected enforce-version latest, got %q", got)
+	}
+}
+
+// TestProvisionNamespaceTemplate tests that the objects are created from the template
+func TestProvisionNamespaceTemplate(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	// stop before the kubeconfig, the objects of a failed provisioning are left to inspect
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		return true, nil, errors.New("stop")
+	})
+	c := &Container{clientset: clientset, config: templateTestConfig()}
+
+	nsSpec := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenama-test-abcde"}}
+	_, err := c.provisionNamespace(context.Background(), nsSpec, &models.Namespace{Template: "small-ci", Users: []models.NamespaceUser{{Name: "jane"}}}, nil, &v1.NamespaceList{}, nil)
+	var pe *provisioningError
+	if !errors.As(err, &pe) || pe.Step != "service-account-token" {
+		t.Fatalf("Expected service-account-token step to fail, got %v", err)
+	}
+
+	// the fake clientset does not remove the objects together with the namespace
+	quota, err := clientset.CoreV1().ResourceQuotas(nsSpec.Name).Get(context.Background(), "tenama-quota", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected quota, got %v", err)
+	}
+	if q := quota.Spec.Hard[v1.ResourceRequestsCPU]; q.String() != "500m" {
+		t.Errorf("Expected cpu quota of the template, got %s", q.String())
+	}
+	lr, err := clientset.CoreV1().LimitRanges(nsSpec.Name).Get(context.Background(), "tenama-limits", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected limit range, got %v", err)
+	}
+	if q := lr.Spec.Limits[0].Default[v1.ResourceMemory]; q.String() != "256Mi" {
+		t.Errorf("Expected default memory limit of the template, got %s", q.String())
+	}
+	rb, err := clientset.RbacV1().RoleBindings(nsSpec.Name).Get(context.Background(), nsSpec.Name+"troubleshooters", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected user rolebinding, got %v", err)
+	}
+	if rb.RoleRef.Name != "admin" {
+		t.Errorf("Expected role of the template, got %s", rb.RoleRef.Name)
+	}
+}
+
+func TestGetTemplates(t *testing.T) {
+	c := &Container{config: templateTestConfig()}
+	tests := []struct {
+		user string
+		want []string
+	}{
+		{"jane", []string{"small-ci"}},
+		{"perf", []string{"small-ci", "large-perf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/templates", nil), rec)
+			ctx.Set(authUserKey, tt.user)
+
+			if err := c.GetTemplates(ctx); err != nil {
+				t.Fatalf("GetTemplates returned error: %v", err)
+			}
+			var response models.GetTemplates200Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Invalid response: %v", err)
+			}
+			var got []string
+			for _, tmpl := range response.Templates {
+				got = append(got, tmpl.Name)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected templates %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Expected templates %v, got %v", tt.want, got)
+				}
+			}
+			if tt.user == "jane" && (response.Templates[0].Quota["requests.cpu"] != "500m" || response.Templates[0].Role != "admin") {
+				t.Errorf("Unexpected template %+v", response.Templates[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// defaultAllowedRoles are the ClusterRoles requests may select if none are configured
+var defaultAllowedRoles = []string{"view", "edit"}
+
+// errRoleNotAllowed is returned when a user requests a role that is not allowed
+var errRoleNotAllowed = errors.New("role is not allowed")
+
+// allowedRoles returns the ClusterRoles requests may bind users to. The role of the
+// template is always allowed.
+func (c *Container) allowedRoles(tmpl *models.NamespaceTemplate) []string {
+	roles := c.config.Namespace.AllowedRoles
+	if len(roles) == 0 {
+		roles = defaultAllowedRoles
+	}
+	if def := templateRole(tmpl); !slices.Contains(roles, def) {
+		roles = append(slices.Clone(roles), def)
+	}
+	return roles
+}
+
+// checkUsers validates the subjects of a request and their roles. Roles that are not
+// in allowed are reported with an error wrapping errRoleNotAllowed.
+func checkUsers(users []models.NamespaceUser, allowed []string) error {
+	for _, user := range users {
+		if user.Name == "" {
+			return errors.New("subject without name")
+		}
+		switch user.Kind {
+		case "", rbacv1.UserKind, rbacv1.GroupKind:
+			if user.Namespace != "" {
+				return fmt.Errorf("only service accounts have a namespace, %s has %s", user.Name, user.Namespace)
+			}
+		case rbacv1.ServiceAccountKind:
+			if errs := validation.IsDNS1123Subdomain(user.Name); len(errs) > 0 {
+				return fmt.Errorf("invalid service account name %s: %s", user.Name, strings.Join(errs, ", "))
+			}
+			if errs := validation.IsDNS1123Label(user.Namespace); len(errs) > 0 {
+				return fmt.Errorf("service account %s requires a valid namespace: %s", user.Name, strings.Join(errs, ", "))
+			}
+		default:
+			return fmt.Errorf("unknown kind %s of %s, expected User, Group or ServiceAccount", user.Kind, user.Name)
+		}
+		if user.Role != "" && !slices.Contains(allowed, user.Role) {
+			return fmt.Errorf("%w: %s for %s", errRoleNotAllowed, user.Role, user.Name)
+		}
+	}
+	return nil
+}
+
+// userSubject converts a requested user into the subject of a RoleBinding
+func userSubject(user models.NamespaceUser) rbacv1.Subject {
+	switch user.Kind {
+	case rbacv1.GroupKind:
+		return rbacv1.Subject{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: user.Name}
+	case rbacv1.ServiceAccountKind:
+		return rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: user.Name, Namespace: user.Namespace}
+	default:
+		return rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: user.Name}
+	}
+}
+
+// isNamespaceUser reports whether the authenticated user created the namespace or is
+// bound to it by tenama, directly or through one of their groups
+func (c *Container) isNamespaceUser(ctx echo.Context, ns *v1.Namespace) (bool, error) {
+	username := authenticatedUser(ctx)
+	if username == "" {
+		return false, nil
+	}
+	if ns.Annotations[creatorAnnotation] == username {
+		return true, nil
+	}
+
+	users, err := c.namespaceUsers(ctx.Request().Context(), ns.Name)
+	if err != nil {
+		return false, err
+	}
+	groups := authenticatedGroups(ctx)
+	for _, user := range users {
+		switch user.Kind {
+		case rbacv1.UserKind:
+			if user.Name == username {
+				return true, nil
+			}
+		case rbacv1.GroupKind:
+			if slices.Contains(groups, user.Name) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
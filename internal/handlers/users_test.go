@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Payback159/tenama/internal/models"
+	"github.com/labstack/echo/v4"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// ownedNamespace returns a namespace created by jane with john and the group qa as users
+func ownedNamespace() (*v1.Namespace, *rbacv1.RoleBinding, *rbacv1.RoleBinding) {
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "tenama-test-abcde",
+			Labels:      map[string]string{"created-by": "tenama"},
+			Annotations: map[string]string{creatorAnnotation: "jane"},
+		},
+	}
+	rb := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenama-test-abcdetroubleshooters", Namespace: "tenama-test-abcde"},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.UserKind, Name: "john"},
+			{Kind: rbacv1.ServiceAccountKind, Name: "tenama-sa"},
+		},
+	}
+	view := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenama-test-abcdetroubleshooters-view", Namespace: "tenama-test-abcde"},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "qa"}},
+	}
+	return ns, rb, view
+}
+
+func TestCheckUsers(t *testing.T) {
+	allowed := []string{"view", "edit"}
+	tests := []struct {
+		name       string
+		user       models.NamespaceUser
+		wantErr    bool
+		wantDenied bool
+	}{
+		{"user", models.NamespaceUser{Name: "jane"}, false, false},
+		{"group with role", models.NamespaceUser{Name: "qa", Kind: rbacv1.GroupKind, Role: "view"}, false, false},
+		{"service account", models.NamespaceUser{Name: "deployer", Kind: rbacv1.ServiceAccountKind, Namespace: "ci"}, false, false},
+		{"service account without namespace", models.NamespaceUser{Name: "deployer", Kind: rbacv1.ServiceAccountKind}, true, false},
+		{"user with namespace", models.NamespaceUser{Name: "jane", Namespace: "ci"}, true, false},
+		{"unknown kind", models.NamespaceUser{Name: "jane", Kind: "Robot"}, true, false},
+		{"without name", models.NamespaceUser{Role: "view"}, true, false},
+		{"role not allowed", models.NamespaceUser{Name: "jane", Role: "cluster-admin"}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkUsers([]models.NamespaceUser{tt.user}, allowed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if denied := errors.Is(err, errRoleNotAllowed); denied != tt.wantDenied {
+				t.Errorf("Expected denied %v, got %v", tt.wantDenied, err)
+			}
+		})
+	}
+}
+
+func TestAllowedRoles(t *testing.T) {
+	c := &Container{config: &models.Config{}}
+	if got := c.allowedRoles(&models.NamespaceTemplate{Role: "admin"}); !reflect.DeepEqual(got, []string{"view", "edit", "admin"}) {
+		t.Errorf("Expected the defaults and the role of the template, got %v", got)
+	}
+	if !reflect.DeepEqual(defaultAllowedRoles, []string{"view", "edit"}) {
+		t.Errorf("Expected the defaults to be left unchanged, got %v", defaultAllowedRoles)
+	}
+
+	c.config.Namespace.AllowedRoles = []string{"view"}
+	if got := c.allowedRoles(nil); !reflect.DeepEqual(got, []string{"view", "edit"}) {
+		t.Errorf("Expected the configured roles and edit, got %v", got)
+	}
+}
+
+// TestCraftUserRolebindings tests that a rolebinding is crafted per role
+func TestCraftUserRolebindings(t *testing.T) {
+	c := &Container{config: &models.Config{}}
+	bindings := c.craftUserRolebindings("tenama-test-abcde", []models.NamespaceUser{
+		{Name: "jane"},
+		{Name: "qa", Kind: rbacv1.GroupKind, Role: "view"},
+		{Name: "deployer", Kind: rbacv1.ServiceAccountKind, Namespace: "ci", Role: "view"},
+		{Name: "john", Role: "edit"},
+	}, "tenama-sa", "edit")
+
+	if len(bindings) != 2 {
+		t.Fatalf("Expected 2 rolebindings, got %d", len(bindings))
+	}
+	if bindings[0].Name != "tenama-test-abcdetroubleshooters" || bindings[0].RoleRef.Name != "edit" {
+		t.Errorf("Unexpected default rolebinding %+v", bindings[0].ObjectMeta)
+	}
+	want := []rbacv1.Subject{
+		{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "jane"},
+		{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "john"},
+		{Kind: rbacv1.ServiceAccountKind, Name: "tenama-sa"},
+	}
+	if !reflect.DeepEqual(bindings[0].Subjects, want) {
+		t.Errorf("Expected %+v, got %+v", want, bindings[0].Subjects)
+	}
+
+	if bindings[1].Name != "tenama-test-abcdetroubleshooters-view" || bindings[1].RoleRef.Name != "view" {
+		t.Errorf("Unexpected view rolebinding %+v", bindings[1].ObjectMeta)
+	}
+	want = []rbacv1.Subject{
+		{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: "qa"},
+		{Kind: rbacv1.ServiceAccountKind, Name: "deployer", Namespace: "ci"},
+	}
+	if !reflect.DeepEqual(bindings[1].Subjects, want) {
+		t.Errorf("Expected %+v, got %+v", want, bindings[1].Subjects)
+	}
+}
+
+func TestIsNamespaceUser(t *testing.T) {
+	ns, rb, view := ownedNamespace()
+	c := &Container{clientset: fake.NewSimpleClientset(ns, rb, view), config: &models.Config{}}
+
+	tests := []struct {
+		name   string
+		user   string
+		groups []string
+		want   bool
+	}{
+		{"creator", "jane", nil, true},
+		{"bound user", "john", nil, true},
+		{"bound group", "mallory", []string{"qa"}, true},
+		{"service account name", "tenama-sa", nil, false},
+		{"other user", "mallory", []string{"dev"}, false},
+		{"unauthenticated", "", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			ctx.Set(authUserKey, tt.user)
+			ctx.Set(authGroupsKey, tt.groups)
+			got, err := c.isNamespaceUser(ctx, ns)
+			if err != nil || got != tt.want {
+				t.Errorf("Expected %v, got %v (%v)", tt.want, got, err)
+			}
+		})
+	}
+}
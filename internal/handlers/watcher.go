@@ -9,11 +9,25 @@ import (
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// watchBackoffInitial and watchBackoffMax bound the delay between failed watch attempts
+	watchBackoffInitial = time.Second
+	watchBackoffMax     = 30 * time.Second
+
+	// stateLabel marks namespaces whose lifetime has ended but which are kept for the grace period
+	stateLabel   = "tenama/state"
+	stateExpired = "expired"
+	// expiredAtAnnotation records when a namespace entered the grace period
+	expiredAtAnnotation = "tenama/expired-at"
 )
 
 // NamespaceGetter is an interface for getting the namespace API
@@ -29,25 +43,48 @@ type NamespaceWatcher struct {
 	timers          map[string]*time.Timer
 	mu              sync.RWMutex
 	done            chan struct{}
+	resourceVersion string // last seen resource version, empty if a re-list is required
+	synced          bool   // guarded by mu
+	leading         bool   // only the leader arms cleanup timers, guarded by mu
+
+	// Pre-expiry notifications
+	notifier       Notifier
+	warningOffsets []time.Duration
+	warnings       map[string][]*time.Timer // warning timers per namespace, guarded by mu
+
+	// Soft-delete grace period and hibernation
+	workloadGetter     WorkloadGetter
+	gracePeriod        time.Duration
+	hibernationEnabled bool
+	defaultHibernation *hibernationSchedule
+	hibernationTimers  map[string]*time.Timer // guarded by mu
+
+	// Pre-delete hooks
+	hooks *HookRunner
 
 	// Global resource tracking
-	currentUsage v1.ResourceList
-	globalLimits v1.ResourceList
-	resourceMu   sync.RWMutex
-	nsResources  map[string]v1.ResourceList // Track resources per namespace
+	currentUsage       v1.ResourceList
+	globalLimits       v1.ResourceList
+	resourceMu         sync.RWMutex
+	nsResources        map[string]v1.ResourceList // Track resources per namespace
+	hibernatedCapacity int                        // percentage of resources counted for hibernated namespaces
 }
 
 // NewNamespaceWatcher creates a new watcher instance
 // Accepts any NamespaceGetter (works with both real clientset and fake)
 func NewNamespaceWatcher(namespaceGetter NamespaceGetter, prefix string) *NamespaceWatcher {
 	return &NamespaceWatcher{
-		namespaceGetter: namespaceGetter,
-		prefix:          prefix,
-		timers:          make(map[string]*time.Timer),
-		done:            make(chan struct{}),
-		currentUsage:    make(v1.ResourceList),
-		globalLimits:    make(v1.ResourceList),
-		nsResources:     make(map[string]v1.ResourceList),
+		namespaceGetter:    namespaceGetter,
+		prefix:             prefix,
+		timers:             make(map[string]*time.Timer),
+		done:               make(chan struct{}),
+		warnings:           make(map[string][]*time.Timer),
+		hibernationTimers:  make(map[string]*time.Timer),
+		currentUsage:       make(v1.ResourceList),
+		globalLimits:       make(v1.ResourceList),
+		nsResources:        make(map[string]v1.ResourceList),
+		hibernatedCapacity: 100,
+		leading:            true,
 	}
 }
 
@@ -56,6 +93,59 @@ func NewNamespaceWatcherFromClientset(clientset *kubernetes.Clientset, prefix st
 	return NewNamespaceWatcher(clientset.CoreV1(), prefix)
 }
 
+// SetNotifier configures the notifier that is triggered the given offsets before a namespace expires
+func (nw *NamespaceWatcher) SetNotifier(notifier Notifier, warningOffsets []time.Duration) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	nw.notifier = notifier
+	nw.warningOffsets = warningOffsets
+}
+
+// SetPreDeleteHooks configures the hooks that run before a namespace is deleted
+func (nw *NamespaceWatcher) SetPreDeleteHooks(hooks *HookRunner) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	nw.hooks = hooks
+}
+
+// SetGracePeriod enables soft-deletion: expired namespaces are scaled to zero and
+// only deleted once the grace period has passed
+func (nw *NamespaceWatcher) SetGracePeriod(workloadGetter WorkloadGetter, gracePeriod time.Duration) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	nw.workloadGetter = workloadGetter
+	nw.gracePeriod = gracePeriod
+}
+
+// SetLeading hands the cleanup of namespaces to or takes it away from this watcher.
+// Resource tracking continues either way so that every replica can enforce the global
+// limits. A new leader re-lists all namespaces to arm their timers.
+func (nw *NamespaceWatcher) SetLeading(ctx context.Context, leading bool) {
+	nw.mu.Lock()
+	wasLeading := nw.leading
+	nw.leading = leading
+	nw.mu.Unlock()
+
+	switch {
+	case leading && !wasLeading:
+		slog.Info("Took over namespace cleanup")
+		if err := nw.initializeExisting(ctx); err != nil {
+			slog.Error("Error initializing namespaces", "error", err)
+			nw.setUnsynced()
+		}
+	case !leading && wasLeading:
+		slog.Info("Handed over namespace cleanup")
+		nw.stopTimers()
+	}
+}
+
+// IsLeading reports whether this watcher is responsible for the cleanup of namespaces
+func (nw *NamespaceWatcher) IsLeading() bool {
+	nw.mu.RLock()
+	defer nw.mu.RUnlock()
+	return nw.leading
+}
+
 // Start begins watching namespaces
 func (nw *NamespaceWatcher) Start(ctx context.Context) error {
 	slog.Info("Starting namespace watcher", "prefix", nw.prefix)
@@ -75,7 +165,9 @@ func (nw *NamespaceWatcher) Stop() {
 	nw.stopAllTimers()
 }
 
-// initializeExisting schedules cleanup for existing namespaces
+// initializeExisting schedules cleanup for existing namespaces. It is also used to
+// re-list after the watch could not be resumed, so namespaces that disappeared in the
+// meantime are released as well.
 func (nw *NamespaceWatcher) initializeExisting(ctx context.Context) error {
 	list, err := nw.namespaceGetter.Namespaces().List(ctx, metav1.ListOptions{
 		LabelSelector: "created-by=tenama",
@@ -86,36 +178,156 @@ func (nw *NamespaceWatcher) initializeExisting(ctx context.Context) error {
 
 	slog.Debug("Found existing namespaces", "count", len(list.Items))
 
+	seen := make(map[string]bool, len(list.Items))
 	for _, ns := range list.Items {
 		if nw.shouldProcess(&ns) {
+			seen[ns.Name] = true
 			nw.schedule(&ns)
-			nw.addToResourceTracking(&ns)
+			nw.updateResourceTracking(&ns)
+		}
+	}
+
+	for _, name := range nw.knownNamespaces() {
+		if !seen[name] {
+			slog.Info("Namespace vanished while not watching", "namespace", name)
+			nw.cancel(name)
+			nw.removeFromResourceTracking(name)
 		}
 	}
+
+	nw.mu.Lock()
+	nw.resourceVersion = list.ResourceVersion
+	nw.synced = true
+	nw.mu.Unlock()
 	return nil
 }
 
-// watch observes namespace events
+// knownNamespaces returns the names of all namespaces with timers or tracked resources
+func (nw *NamespaceWatcher) knownNamespaces() []string {
+	names := make(map[string]bool)
+	nw.mu.RLock()
+	for name := range nw.timers {
+		names[name] = true
+	}
+	for name := range nw.hibernationTimers {
+		names[name] = true
+	}
+	nw.mu.RUnlock()
+
+	nw.resourceMu.RLock()
+	for name := range nw.nsResources {
+		names[name] = true
+	}
+	nw.resourceMu.RUnlock()
+
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	return result
+}
+
+// HasSynced reports whether the watcher has listed all namespaces and is not
+// waiting for a re-list after losing its watch
+func (nw *NamespaceWatcher) HasSynced() bool {
+	nw.mu.RLock()
+	defer nw.mu.RUnlock()
+	return nw.synced
+}
+
+// setUnsynced forgets the resource version so that the next attempt re-lists
+func (nw *NamespaceWatcher) setUnsynced() {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	nw.resourceVersion = ""
+	nw.synced = false
+}
+
+// watch observes namespace events until the watcher is stopped. Closed watches are
+// resumed from the last seen resource version, and the namespaces are re-listed if
+// that version is no longer available (410 Gone).
 func (nw *NamespaceWatcher) watch(ctx context.Context) {
+	backoff := watchBackoffInitial
+	for {
+		select {
+		case <-nw.done:
+			return
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if err := nw.watchOnce(ctx); err != nil {
+			slog.Error("Namespace watch failed, retrying", "error", err, "backoff", backoff.String())
+			select {
+			case <-nw.done:
+				return
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, watchBackoffMax)
+			continue
+		}
+		backoff = watchBackoffInitial
+	}
+}
+
+// watchOnce re-lists if necessary and processes events of a single watch until it
+// closes. It returns an error if the watch could not be established.
+func (nw *NamespaceWatcher) watchOnce(ctx context.Context) error {
+	nw.mu.RLock()
+	resourceVersion := nw.resourceVersion
+	nw.mu.RUnlock()
+
+	if resourceVersion == "" {
+		slog.Info("Listing namespaces to resynchronize the watcher")
+		if err := nw.initializeExisting(ctx); err != nil {
+			return err
+		}
+		nw.mu.RLock()
+		resourceVersion = nw.resourceVersion
+		nw.mu.RUnlock()
+	}
+
 	watcher, err := nw.namespaceGetter.Namespaces().Watch(ctx, metav1.ListOptions{
-		LabelSelector: "created-by=tenama",
+		LabelSelector:       "created-by=tenama",
+		ResourceVersion:     resourceVersion,
+		AllowWatchBookmarks: true,
 	})
 	if err != nil {
-		slog.Error("Error watching namespaces", "error", err)
-		return
+		if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			slog.Warn("Resource version is too old, re-listing namespaces", "resourceVersion", resourceVersion)
+			nw.setUnsynced()
+			return nil
+		}
+		nw.setUnsynced()
+		return fmt.Errorf("failed to watch namespaces: %w", err)
 	}
 	defer watcher.Stop()
 
-	slog.Info("Namespace watcher running")
+	slog.Info("Namespace watcher running", "resourceVersion", resourceVersion)
 
 	for {
 		select {
 		case <-nw.done:
-			return
+			return nil
+		case <-ctx.Done():
+			return nil
 		case event, ok := <-watcher.ResultChan():
 			if !ok {
-				slog.Warn("Watcher channel closed")
-				return
+				slog.Info("Namespace watch closed, resuming", "resourceVersion", nw.currentResourceVersion())
+				return nil
+			}
+
+			if event.Type == watch.Error {
+				status := apierrors.FromObject(event.Object)
+				if apierrors.IsResourceExpired(status) || apierrors.IsGone(status) {
+					slog.Warn("Resource version is too old, re-listing namespaces", "error", status)
+					nw.setUnsynced()
+					return nil
+				}
+				return fmt.Errorf("namespace watch error: %w", status)
 			}
 
 			ns, ok := event.Object.(*v1.Namespace)
@@ -123,11 +335,15 @@ func (nw *NamespaceWatcher) watch(ctx context.Context) {
 				continue
 			}
 
+			nw.mu.Lock()
+			nw.resourceVersion = ns.ResourceVersion
+			nw.mu.Unlock()
+
 			switch event.Type {
 			case watch.Added:
 				if nw.shouldProcess(ns) {
 					nw.schedule(ns)
-					nw.addToResourceTracking(ns)
+					nw.updateResourceTracking(ns)
 				}
 			case watch.Modified:
 				if nw.shouldProcess(ns) {
@@ -145,12 +361,24 @@ func (nw *NamespaceWatcher) watch(ctx context.Context) {
 	}
 }
 
+// currentResourceVersion returns the last resource version seen by the watcher
+func (nw *NamespaceWatcher) currentResourceVersion() string {
+	nw.mu.RLock()
+	defer nw.mu.RUnlock()
+	return nw.resourceVersion
+}
+
 // shouldProcess checks if namespace should be cleaned up
 func (nw *NamespaceWatcher) shouldProcess(ns *v1.Namespace) bool {
 	if ns.Name == "tenama-system" {
 		return false
 	}
 
+	// terminating namespaces are already being deleted, the hooks must not run again
+	if ns.DeletionTimestamp != nil {
+		return false
+	}
+
 	if !strings.HasPrefix(ns.Name, nw.prefix) {
 		return false
 	}
@@ -161,20 +389,27 @@ func (nw *NamespaceWatcher) shouldProcess(ns *v1.Namespace) bool {
 
 // schedule creates a cleanup timer for a namespace
 func (nw *NamespaceWatcher) schedule(ns *v1.Namespace) {
-	durationStr := ns.Labels["tenama/namespace-duration"]
-	duration, err := time.ParseDuration(durationStr)
+	if !nw.IsLeading() {
+		return
+	}
+
+	if ns.Labels[stateLabel] == stateExpired {
+		nw.scheduleGraceDeletion(ns)
+		return
+	}
+
+	expirationTime, err := namespaceExpiration(ns)
 	if err != nil {
-		slog.Error("Failed to parse duration", "namespace", ns.Name, "error", err)
+		slog.Error("Failed to determine expiry", "namespace", ns.Name, "error", err)
 		return
 	}
 
-	creationTime := ns.ObjectMeta.CreationTimestamp.Time
-	expirationTime := creationTime.Add(duration)
 	timeUntilExpiration := time.Until(expirationTime)
 
 	if timeUntilExpiration <= 0 {
-		slog.Info("Namespace already expired, deleting", "namespace", ns.Name)
-		nw.delete(ns.Name)
+		slog.Info("Namespace already expired", "namespace", ns.Name)
+		nw.cancel(ns.Name)
+		nw.expire(ns.Name)
 		return
 	}
 
@@ -184,17 +419,173 @@ func (nw *NamespaceWatcher) schedule(ns *v1.Namespace) {
 	}
 
 	nw.timers[ns.Name] = time.AfterFunc(timeUntilExpiration, func() {
-		slog.Info("Deleting namespace (lifetime expired)", "namespace", ns.Name)
-		nw.delete(ns.Name)
 		nw.mu.Lock()
 		delete(nw.timers, ns.Name)
+		delete(nw.warnings, ns.Name)
 		nw.mu.Unlock()
+		nw.expire(ns.Name)
 	})
+	nw.scheduleWarnings(ns, expirationTime)
+	nw.scheduleHibernation(ns)
 	nw.mu.Unlock()
 
 	slog.Info("Scheduled cleanup", "namespace", ns.Name, "duration", timeUntilExpiration.String())
 }
 
+// scheduleGraceDeletion creates the deletion timer for a namespace in the grace period
+func (nw *NamespaceWatcher) scheduleGraceDeletion(ns *v1.Namespace) {
+	expiredAt, err := time.Parse(time.RFC3339, ns.Annotations[expiredAtAnnotation])
+	if err != nil {
+		// without a valid timestamp the grace period starts now
+		slog.Warn("Missing or invalid expiry timestamp, starting grace period now", "namespace", ns.Name, "error", err)
+		expiredAt = time.Now()
+	}
+
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	deletionTime := expiredAt.Add(nw.gracePeriod)
+	timeUntilDeletion := time.Until(deletionTime)
+
+	if existing, ok := nw.timers[ns.Name]; ok {
+		existing.Stop()
+	}
+	for _, timer := range nw.warnings[ns.Name] {
+		timer.Stop()
+	}
+	delete(nw.warnings, ns.Name)
+	nw.scheduleHibernation(ns)
+
+	nw.timers[ns.Name] = time.AfterFunc(timeUntilDeletion, func() {
+		slog.Info("Deleting namespace (grace period expired)", "namespace", ns.Name)
+		nw.mu.Lock()
+		delete(nw.timers, ns.Name)
+		nw.mu.Unlock()
+		nw.delete(ns.Name)
+	})
+
+	slog.Info("Scheduled deletion after grace period", "namespace", ns.Name, "duration", timeUntilDeletion.String())
+}
+
+// expire handles a namespace whose lifetime has ended. Without a grace period the
+// namespace is deleted right away, otherwise its workloads are scaled to zero and
+// the namespace is marked as expired until the grace period has passed.
+func (nw *NamespaceWatcher) expire(namespaceName string) {
+	nw.mu.RLock()
+	workloadGetter := nw.workloadGetter
+	gracePeriod := nw.gracePeriod
+	leading := nw.leading
+	nw.mu.RUnlock()
+
+	if !leading {
+		// a timer that fired during a handover, the new leader takes care of it
+		return
+	}
+
+	if workloadGetter == nil || gracePeriod <= 0 {
+		slog.Info("Deleting namespace (lifetime expired)", "namespace", namespaceName)
+		nw.delete(namespaceName)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	slog.Info("Scaling down namespace (lifetime expired)", "namespace", namespaceName, "gracePeriod", gracePeriod.String())
+	if err := scaleDownWorkloads(ctx, workloadGetter, namespaceName); err != nil {
+		slog.Error("Error scaling down workloads", "namespace", namespaceName, "error", err)
+	}
+
+	var expired *v1.Namespace
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ns, err := nw.namespaceGetter.Namespaces().Get(ctx, namespaceName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if ns.Labels == nil {
+			ns.Labels = make(map[string]string)
+		}
+		if ns.Annotations == nil {
+			ns.Annotations = make(map[string]string)
+		}
+		ns.Labels[stateLabel] = stateExpired
+		ns.Annotations[expiredAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		expired, err = nw.namespaceGetter.Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		slog.Error("Error marking namespace as expired, deleting", "namespace", namespaceName, "error", err)
+		nw.delete(namespaceName)
+		return
+	}
+
+	nw.schedule(expired)
+	nw.notify(NotificationEvent{
+		Type:      EventExpired,
+		Namespace: namespaceName,
+		ExpiresAt: time.Now().Add(gracePeriod).UTC(),
+		Remaining: gracePeriod.String(),
+		Labels:    expired.Labels,
+	})
+}
+
+// scheduleWarnings replaces the warning timers of a namespace, skipping offsets
+// that already lie in the past. Callers must hold nw.mu.
+func (nw *NamespaceWatcher) scheduleWarnings(ns *v1.Namespace, expirationTime time.Time) {
+	for _, timer := range nw.warnings[ns.Name] {
+		timer.Stop()
+	}
+	delete(nw.warnings, ns.Name)
+
+	if nw.notifier == nil {
+		return
+	}
+
+	event := NotificationEvent{
+		Type:      EventExpiryWarning,
+		Namespace: ns.Name,
+		ExpiresAt: expirationTime.UTC(),
+		Labels:    ns.Labels,
+	}
+
+	var timers []*time.Timer
+	for _, offset := range nw.warningOffsets {
+		timeUntilWarning := time.Until(expirationTime.Add(-offset))
+		if timeUntilWarning <= 0 {
+			continue
+		}
+
+		warning := event
+		warning.Remaining = offset.String()
+		timers = append(timers, time.AfterFunc(timeUntilWarning, func() {
+			nw.notify(warning)
+		}))
+	}
+
+	if len(timers) > 0 {
+		nw.warnings[ns.Name] = timers
+	}
+}
+
+// notify sends an event through the configured notifier and logs the outcome
+func (nw *NamespaceWatcher) notify(event NotificationEvent) {
+	nw.mu.RLock()
+	notifier := nw.notifier
+	nw.mu.RUnlock()
+	if notifier == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := notifier.Notify(ctx, event); err != nil {
+		slog.Error("Error sending notification", "namespace", event.Namespace, "type", event.Type, "error", err)
+		return
+	}
+	slog.Info("Sent notification", "namespace", event.Namespace, "type", event.Type, "remaining", event.Remaining)
+}
+
 // cancel stops cleanup timer for a namespace
 func (nw *NamespaceWatcher) cancel(namespaceName string) {
 	nw.mu.Lock()
@@ -204,10 +595,30 @@ func (nw *NamespaceWatcher) cancel(namespaceName string) {
 		timer.Stop()
 		delete(nw.timers, namespaceName)
 	}
+
+	for _, timer := range nw.warnings[namespaceName] {
+		timer.Stop()
+	}
+	delete(nw.warnings, namespaceName)
+
+	if timer, ok := nw.hibernationTimers[namespaceName]; ok {
+		timer.Stop()
+		delete(nw.hibernationTimers, namespaceName)
+	}
 }
 
 // stopAllTimers stops all active timers and clears resource tracking
 func (nw *NamespaceWatcher) stopAllTimers() {
+	nw.stopTimers()
+
+	nw.resourceMu.Lock()
+	defer nw.resourceMu.Unlock()
+	nw.currentUsage = make(v1.ResourceList)
+	nw.nsResources = make(map[string]v1.ResourceList)
+}
+
+// stopTimers stops all cleanup, warning and hibernation timers
+func (nw *NamespaceWatcher) stopTimers() {
 	nw.mu.Lock()
 	defer nw.mu.Unlock()
 
@@ -216,14 +627,36 @@ func (nw *NamespaceWatcher) stopAllTimers() {
 	}
 	nw.timers = make(map[string]*time.Timer)
 
-	nw.resourceMu.Lock()
-	defer nw.resourceMu.Unlock()
-	nw.currentUsage = make(v1.ResourceList)
-	nw.nsResources = make(map[string]v1.ResourceList)
+	for _, timers := range nw.warnings {
+		for _, timer := range timers {
+			timer.Stop()
+		}
+	}
+	nw.warnings = make(map[string][]*time.Timer)
+
+	for _, timer := range nw.hibernationTimers {
+		timer.Stop()
+	}
+	nw.hibernationTimers = make(map[string]*time.Timer)
 }
 
-// delete removes a namespace
+// delete runs the pre-delete hooks, removes a namespace and releases its tracked resources
 func (nw *NamespaceWatcher) delete(namespaceName string) {
+	nw.mu.RLock()
+	hooks := nw.hooks
+	nw.mu.RUnlock()
+
+	if hooks != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ns, err := nw.namespaceGetter.Namespaces().Get(ctx, namespaceName, metav1.GetOptions{})
+		cancel()
+		if err != nil {
+			slog.Error("Error getting namespace for pre-delete hooks", "namespace", namespaceName, "error", err)
+		} else {
+			hooks.RunPreDelete(ns, HookTriggerExpired)
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -232,9 +665,18 @@ func (nw *NamespaceWatcher) delete(namespaceName string) {
 		slog.Error("Error deleting namespace", "namespace", namespaceName, "error", err)
 	} else {
 		slog.Info("Successfully deleted namespace", "namespace", namespaceName)
+		nw.removeFromResourceTracking(namespaceName)
 	}
 }
 
+// reclaim ends the lifetime of a namespace ahead of its expiry. Like a regular
+// expiry it honors the grace period if one is configured.
+func (nw *NamespaceWatcher) reclaim(namespaceName string, rule string) {
+	slog.Info("Reclaiming namespace before its expiry", "namespace", namespaceName, "rule", rule)
+	nw.cancel(namespaceName)
+	nw.expire(namespaceName)
+}
+
 // GetActiveTimerCount returns the number of active timers
 func (nw *NamespaceWatcher) GetActiveTimerCount() int {
 	nw.mu.RLock()
@@ -259,7 +701,7 @@ func (nw *NamespaceWatcher) addToResourceTracking(ns *v1.Namespace) {
 	defer nw.resourceMu.Unlock()
 
 	// Extract resources from namespace spec (from requests)
-	resources := extractNamespaceResources(ns)
+	resources := nw.trackedResources(ns)
 	nw.nsResources[ns.Name] = resources.DeepCopy()
 
 	// Add to current usage
@@ -321,7 +763,7 @@ func (nw *NamespaceWatcher) updateResourceTracking(ns *v1.Namespace) {
 		return
 	}
 
-	newResources := extractNamespaceResources(ns)
+	newResources := nw.trackedResources(ns)
 
 	// Remove old resources
 	for key, val := range oldResources {
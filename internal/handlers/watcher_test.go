@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"strings"
 	"testing"
 	"time"
@@ -9,7 +11,9 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestNewNamespaceWatcher(t *testing.T) {
@@ -82,6 +86,19 @@ func TestShouldProcess(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			name: "terminating",
+			namespace: &v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "test-ns",
+					DeletionTimestamp: &metav1.Time{Time: time.Now()},
+					Labels: map[string]string{
+						"tenama/namespace-duration": "5m",
+					},
+				},
+			},
+			want: false,
+		},
 		{
 			name: "no duration label",
 			namespace: &v1.Namespace{
@@ -496,3 +513,237 @@ func parseQuantity(str string) resource.Quantity {
 	q, _ := resource.ParseQuantity(str)
 	return q
 }
+
+// recordingNotifier collects notification events for assertions
+type recordingNotifier struct {
+	events chan NotificationEvent
+}
+
+func (rn *recordingNotifier) Notify(ctx context.Context, event NotificationEvent) error {
+	rn.events <- event
+	return nil
+}
+
+// TestScheduleWarnings tests that warning timers are armed and cancelled with the cleanup timer
+func TestScheduleWarnings(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	notifier := &recordingNotifier{events: make(chan NotificationEvent, 1)}
+	// 3h lies before the creation of the namespace and must be skipped
+	watcher.SetNotifier(notifier, []time.Duration{3 * time.Hour, 2*time.Hour - 50*time.Millisecond, time.Hour})
+
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "tenama-test-1",
+			CreationTimestamp: metav1.Now(),
+			Labels: map[string]string{
+				"tenama/namespace-duration": "2h",
+			},
+		},
+	}
+	watcher.schedule(ns)
+
+	watcher.mu.RLock()
+	warningCount := len(watcher.warnings[ns.Name])
+	watcher.mu.RUnlock()
+	if warningCount != 2 {
+		t.Fatalf("Expected 2 warning timers, got %d", warningCount)
+	}
+
+	select {
+	case event := <-notifier.events:
+		if event.Type != EventExpiryWarning || event.Namespace != ns.Name {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected warning notification to be sent")
+	}
+
+	watcher.cancel(ns.Name)
+
+	watcher.mu.RLock()
+	warningCount = len(watcher.warnings[ns.Name])
+	watcher.mu.RUnlock()
+	if warningCount != 0 {
+		t.Errorf("Expected 0 warning timers after cancel, got %d", warningCount)
+	}
+}
+
+// TestExpireWithGracePeriod tests that expired namespaces are scaled down and kept for the grace period
+func TestExpireWithGracePeriod(t *testing.T) {
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "tenama-test-1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+			Labels: map[string]string{
+				"created-by":                "tenama",
+				"tenama/namespace-duration": "1h",
+			},
+		},
+	}
+	clientset := fake.NewSimpleClientset(ns)
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	watcher.SetGracePeriod(clientset.AppsV1(), time.Hour)
+
+	watcher.schedule(ns)
+
+	got, err := clientset.CoreV1().Namespaces().Get(context.Background(), ns.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected namespace to be kept during grace period: %v", err)
+	}
+	if got.Labels[stateLabel] != stateExpired {
+		t.Errorf("Expected namespace to be labeled as expired, got %q", got.Labels[stateLabel])
+	}
+	if _, ok := got.Annotations[expiredAtAnnotation]; !ok {
+		t.Error("Expected expired-at annotation to be set")
+	}
+	if count := watcher.GetActiveTimerCount(); count != 1 {
+		t.Errorf("Expected 1 grace period timer, got %d", count)
+	}
+
+	watcher.Stop()
+}
+
+// TestExpireWithoutGracePeriod tests that expired namespaces are deleted right away by default
+func TestExpireWithoutGracePeriod(t *testing.T) {
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "tenama-test-1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+			Labels: map[string]string{
+				"created-by":                "tenama",
+				"tenama/namespace-duration": "1h",
+			},
+		},
+	}
+	clientset := fake.NewSimpleClientset(ns)
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+
+	watcher.schedule(ns)
+
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), ns.Name, metav1.GetOptions{}); err == nil {
+		t.Error("Expected namespace to be deleted")
+	}
+}
+
+// watchSequence hands out fake watches in order and records the requested resource versions
+type watchSequence struct {
+	watches          chan *watch.FakeWatcher
+	resourceVersions chan string
+}
+
+func newWatchSequence(clientset *fake.Clientset) *watchSequence {
+	ws := &watchSequence{
+		watches:          make(chan *watch.FakeWatcher, 10),
+		resourceVersions: make(chan string, 10),
+	}
+	clientset.PrependWatchReactor("namespaces", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		ws.resourceVersions <- action.(k8stesting.WatchAction).GetWatchRestrictions().ResourceVersion
+		w := watch.NewFake()
+		ws.watches <- w
+		return true, w, nil
+	})
+	return ws
+}
+
+func (ws *watchSequence) next(t *testing.T) (*watch.FakeWatcher, string) {
+	t.Helper()
+	select {
+	case w := <-ws.watches:
+		return w, <-ws.resourceVersions
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for watch")
+		return nil, ""
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func watchTestNamespace(name string, resourceVersion string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			ResourceVersion:   resourceVersion,
+			CreationTimestamp: metav1.Now(),
+			Labels: map[string]string{
+				"created-by":                "tenama",
+				"tenama/namespace-duration": "1h",
+			},
+		},
+	}
+}
+
+// TestWatchResumesAfterClose tests that a closed watch is resumed from the last resource version
+func TestWatchResumesAfterClose(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	watches := newWatchSequence(clientset)
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	defer watcher.Stop()
+
+	first, _ := watches.next(t)
+	if !watcher.HasSynced() {
+		t.Error("Expected watcher to be synced after the initial list")
+	}
+	first.Add(watchTestNamespace("tenama-first", "42"))
+	first.Stop()
+
+	second, resourceVersion := watches.next(t)
+	if resourceVersion != "42" {
+		t.Errorf("Expected watch to resume from resource version 42, got %q", resourceVersion)
+	}
+	second.Add(watchTestNamespace("tenama-second", "43"))
+
+	waitFor(t, func() bool { return watcher.GetActiveTimerCount() == 2 }, "Expected timers for both namespaces")
+}
+
+// TestWatchRelistsOnGone tests that an expired resource version triggers a re-list
+func TestWatchRelistsOnGone(t *testing.T) {
+	stale := watchTestNamespace("tenama-stale", "")
+	clientset := fake.NewSimpleClientset(stale)
+	watches := newWatchSequence(clientset)
+	watcher := NewNamespaceWatcher(clientset.CoreV1(), "tenama")
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	defer watcher.Stop()
+
+	first, _ := watches.next(t)
+	if count := watcher.GetActiveTimerCount(); count != 1 {
+		t.Fatalf("Expected 1 timer after the initial list, got %d", count)
+	}
+
+	// changes that happen while the watch is lost must be picked up by the re-list
+	if err := clientset.CoreV1().Namespaces().Delete(context.Background(), stale.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete namespace: %v", err)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Create(context.Background(), watchTestNamespace("tenama-new", ""), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	first.Error(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusGone, Reason: metav1.StatusReasonExpired})
+
+	watches.next(t)
+	waitFor(t, watcher.HasSynced, "Expected watcher to be synced after the re-list")
+
+	watcher.mu.RLock()
+	_, hasStale := watcher.timers["tenama-stale"]
+	_, hasNew := watcher.timers["tenama-new"]
+	watcher.mu.RUnlock()
+	if hasStale {
+		t.Error("Expected timer of the deleted namespace to be cancelled")
+	}
+	if !hasNew {
+		t.Error("Expected timer for the namespace created while the watch was lost")
+	}
+}
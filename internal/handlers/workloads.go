@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+)
+
+// originalReplicasAnnotation stores the replica count of a workload that was scaled to zero by tenama
+const originalReplicasAnnotation = "tenama/original-replicas"
+
+// WorkloadGetter is an interface for getting the workload APIs that tenama scales
+type WorkloadGetter interface {
+	Deployments(namespace string) appsv1client.DeploymentInterface
+	StatefulSets(namespace string) appsv1client.StatefulSetInterface
+}
+
+// scaleDownWorkloads scales all Deployments and StatefulSets of a namespace to zero.
+// Workloads that were already scaled down by tenama keep their recorded replica count.
+func scaleDownWorkloads(ctx context.Context, workloadGetter WorkloadGetter, namespace string) error {
+	var errs []error
+
+	deployments, err := workloadGetter.Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		replicas, changed := recordAndZeroReplicas(&d.ObjectMeta, d.Spec.Replicas)
+		if !changed {
+			continue
+		}
+		d.Spec.Replicas = replicas
+		if _, err := workloadGetter.Deployments(namespace).Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to scale down deployment %s: %w", d.Name, err))
+		}
+	}
+
+	statefulSets, err := workloadGetter.StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Join(append(errs, fmt.Errorf("failed to list statefulsets: %w", err))...)
+	}
+	for i := range statefulSets.Items {
+		s := &statefulSets.Items[i]
+		replicas, changed := recordAndZeroReplicas(&s.ObjectMeta, s.Spec.Replicas)
+		if !changed {
+			continue
+		}
+		s.Spec.Replicas = replicas
+		if _, err := workloadGetter.StatefulSets(namespace).Update(ctx, s, metav1.UpdateOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to scale down statefulset %s: %w", s.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// restoreWorkloads scales all Deployments and StatefulSets of a namespace back to
+// the replica count recorded by scaleDownWorkloads
+func restoreWorkloads(ctx context.Context, workloadGetter WorkloadGetter, namespace string) error {
+	var errs []error
+
+	deployments, err := workloadGetter.Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		replicas, changed, err := restoreReplicas(&d.ObjectMeta)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("deployment %s: %w", d.Name, err))
+			continue
+		}
+		if !changed {
+			continue
+		}
+		d.Spec.Replicas = replicas
+		if _, err := workloadGetter.Deployments(namespace).Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore deployment %s: %w", d.Name, err))
+		}
+	}
+
+	statefulSets, err := workloadGetter.StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Join(append(errs, fmt.Errorf("failed to list statefulsets: %w", err))...)
+	}
+	for i := range statefulSets.Items {
+		s := &statefulSets.Items[i]
+		replicas, changed, err := restoreReplicas(&s.ObjectMeta)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("statefulset %s: %w", s.Name, err))
+			continue
+		}
+		if !changed {
+			continue
+		}
+		s.Spec.Replicas = replicas
+		if _, err := workloadGetter.StatefulSets(namespace).Update(ctx, s, metav1.UpdateOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore statefulset %s: %w", s.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// recordAndZeroReplicas stores the current replica count in the annotations of the
+// object and returns zero replicas. It reports false if the object is already scaled down.
+func recordAndZeroReplicas(meta *metav1.ObjectMeta, replicas *int32) (*int32, bool) {
+	if _, ok := meta.Annotations[originalReplicasAnnotation]; ok {
+		return replicas, false
+	}
+
+	// a missing replica count defaults to 1 in Kubernetes
+	current := int32(1)
+	if replicas != nil {
+		current = *replicas
+	}
+
+	if meta.Annotations == nil {
+		meta.Annotations = make(map[string]string)
+	}
+	meta.Annotations[originalReplicasAnnotation] = strconv.Itoa(int(current))
+
+	zero := int32(0)
+	return &zero, true
+}
+
+// restoreReplicas returns the recorded replica count of the object and removes the
+// annotation. It reports false if the object was not scaled down by tenama.
+func restoreReplicas(meta *metav1.ObjectMeta) (*int32, bool, error) {
+	value, ok := meta.Annotations[originalReplicasAnnotation]
+	if !ok {
+		return nil, false, nil
+	}
+
+	replicas, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid replica annotation %q: %w", value, err)
+	}
+	delete(meta.Annotations, originalReplicasAnnotation)
+
+	restored := int32(replicas)
+	return &restored, true, nil
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestScaleDownAndRestoreWorkloads(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenama-test"},
+			Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(3)},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "tenama-test"},
+		},
+	)
+	ctx := context.Background()
+
+	if err := scaleDownWorkloads(ctx, clientset.AppsV1(), "tenama-test"); err != nil {
+		t.Fatalf("scaleDownWorkloads returned error: %v", err)
+	}
+
+	d, _ := clientset.AppsV1().Deployments("tenama-test").Get(ctx, "web", metav1.GetOptions{})
+	if *d.Spec.Replicas != 0 || d.Annotations[originalReplicasAnnotation] != "3" {
+		t.Errorf("Expected deployment scaled to 0 with annotation 3, got %d and %q", *d.Spec.Replicas, d.Annotations[originalReplicasAnnotation])
+	}
+	s, _ := clientset.AppsV1().StatefulSets("tenama-test").Get(ctx, "db", metav1.GetOptions{})
+	if *s.Spec.Replicas != 0 || s.Annotations[originalReplicasAnnotation] != "1" {
+		t.Errorf("Expected statefulset scaled to 0 with annotation 1, got %d and %q", *s.Spec.Replicas, s.Annotations[originalReplicasAnnotation])
+	}
+
+	// scaling down again must not overwrite the recorded replica count
+	if err := scaleDownWorkloads(ctx, clientset.AppsV1(), "tenama-test"); err != nil {
+		t.Fatalf("scaleDownWorkloads returned error: %v", err)
+	}
+	d, _ = clientset.AppsV1().Deployments("tenama-test").Get(ctx, "web", metav1.GetOptions{})
+	if d.Annotations[originalReplicasAnnotation] != "3" {
+		t.Errorf("Expected recorded replicas to stay 3, got %q", d.Annotations[originalReplicasAnnotation])
+	}
+
+	if err := restoreWorkloads(ctx, clientset.AppsV1(), "tenama-test"); err != nil {
+		t.Fatalf("restoreWorkloads returned error: %v", err)
+	}
+
+	d, _ = clientset.AppsV1().Deployments("tenama-test").Get(ctx, "web", metav1.GetOptions{})
+	if *d.Spec.Replicas != 3 {
+		t.Errorf("Expected deployment restored to 3 replicas, got %d", *d.Spec.Replicas)
+	}
+	if _, ok := d.Annotations[originalReplicasAnnotation]; ok {
+		t.Error("Expected replica annotation to be removed after restore")
+	}
+	s, _ = clientset.AppsV1().StatefulSets("tenama-test").Get(ctx, "db", metav1.GetOptions{})
+	if *s.Spec.Replicas != 1 {
+		t.Errorf("Expected statefulset restored to 1 replica, got %d", *s.Spec.Replicas)
+	}
+}
@@ -0,0 +1,10 @@
+package models
+
+// BundleObjectStatus reports the outcome of creating an object of the manifest bundle
+type BundleObjectStatus struct {
+	Source string `json:"source"` // file or config map key the object was rendered from
+	Kind   string `json:"kind,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Status string `json:"status"` // created or failed
+	Error  string `json:"error,omitempty"`
+}
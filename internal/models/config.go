@@ -8,18 +8,243 @@ type Config struct {
 		ClusterEndpoint string `yaml:"clusterEndpoint"`
 	}
 	Namespace struct {
-		Prefix    string    `yaml:"prefix"`
-		Suffix    string    `yaml:"suffix"`
-		Duration  string    `yaml:"duration"`
-		Resources Resources `yaml:"resources"`
+		Prefix string `yaml:"prefix"`
+		// Template of namespace names, defaults to "{prefix}-{infix}-{suffix}"
+		NameTemplate   string         `yaml:"nameTemplate"`
+		Suffix         string         `yaml:"suffix"`
+		Duration       string         `yaml:"duration"` // used if a request does not specify a duration
+		DurationPolicy DurationPolicy `yaml:"durationPolicy"`
+		Resources      Resources      `yaml:"resources"`
+		// Upper bounds for the resources a single namespace may request
+		MaxResources ResourceMaximums `yaml:"maxResources"`
+		Extension    Extension        `yaml:"extension"`
+		// How long expired namespaces are kept scaled to zero before deletion, empty disables soft-deletion
+		GracePeriod string            `yaml:"gracePeriod"`
+		Hibernation HibernationConfig `yaml:"hibernation"`
+		LimitRange  LimitRangeConfig  `yaml:"limitRange"`
+		// Isolates new namespaces from the traffic of other namespaces
+		NetworkPolicy NetworkPolicyConfig `yaml:"networkPolicy"`
+		PodSecurity   PodSecurityConfig   `yaml:"podSecurity"`
+		Copy          CopyConfig          `yaml:"copy"`
+		Bundle        BundleConfig        `yaml:"bundle"`
+		// ClusterRoles requests may bind users to besides the role of the template, defaults to view and edit
+		AllowedRoles []string `yaml:"allowedRoles"`
+		// Named settings that requests can select instead of the defaults above
+		Templates []NamespaceTemplate `yaml:"templates"`
 	} `yaml:"namespace"`
-	BasicAuth BasicAuth `yaml:"basicAuth"`
+	Notifications  Notifications  `yaml:"notifications"`
+	IdleDetection  IdleDetection  `yaml:"idleDetection"`
+	LeaderElection LeaderElection `yaml:"leaderElection"`
+	Hooks          Hooks          `yaml:"hooks"`
+	Operations     Operations     `yaml:"operations"`
+	Idempotency    Idempotency    `yaml:"idempotency"`
+	BasicAuth      BasicAuth      `yaml:"basicAuth"`
+}
+
+// Operations configures the records of asynchronous namespace creations
+type Operations struct {
+	TTL string `yaml:"ttl"` // how long finished operations can be queried, defaults to 1h
+}
+
+// Idempotency configures how long the results of requests with an Idempotency-Key are kept
+type Idempotency struct {
+	TTL string `yaml:"ttl"` // defaults to 24h
+}
+
+// Hooks configures actions that run at points in the lifecycle of a namespace
+type Hooks struct {
+	PreDelete []Hook `yaml:"preDelete"` // run before a namespace is deleted, on expiry or through the API
+}
+
+// Hook is either an exec hook (Command) or an HTTP hook (URL)
+type Hook struct {
+	Name     string   `yaml:"name"`
+	Command  []string `yaml:"command"`  // executed with the namespace metadata as JSON on stdin and in TENAMA_* variables
+	URL      string   `yaml:"url"`      // receives the namespace metadata as JSON via POST
+	Secret   string   `yaml:"secret"`   // used to sign the HTTP payload with HMAC-SHA256
+	Timeout  string   `yaml:"timeout"`  // defaults to 30s
+	Blocking bool     `yaml:"blocking"` // delay the deletion until the hook has finished or timed out
+}
+
+// LeaderElection lets multiple replicas share the work: all of them serve the API,
+// but only the leader cleans up namespaces
+type LeaderElection struct {
+	Enabled        bool   `yaml:"enabled"`
+	LeaseName      string `yaml:"leaseName"`      // defaults to "tenama"
+	LeaseNamespace string `yaml:"leaseNamespace"` // defaults to "tenama-system"
+	LeaseDuration  string `yaml:"leaseDuration"`  // defaults to 15s
+	RenewDeadline  string `yaml:"renewDeadline"`  // defaults to 10s
+	RetryPeriod    string `yaml:"retryPeriod"`    // defaults to 2s
+}
+
+// IdleDetection configures the early reclamation of namespaces without activity
+type IdleDetection struct {
+	Enabled           bool   `yaml:"enabled"`
+	Interval          string `yaml:"interval"`          // how often namespaces are checked, defaults to 5m
+	Threshold         string `yaml:"threshold"`         // how long a namespace may be idle before the action is taken
+	Action            string `yaml:"action"`            // "delete" or "warn", defaults to "warn"
+	RecentPodCreation bool   `yaml:"recentPodCreation"` // also count recently created pods as activity
+}
+
+// Notifications configures warnings that are sent before a namespace expires
+type Notifications struct {
+	Enabled        bool     `yaml:"enabled"`
+	WarningOffsets []string `yaml:"warningOffsets"` // durations before expiry, e.g. "24h", "1h", "10m"
+	Webhook        Webhook  `yaml:"webhook"`
+}
+
+// Webhook configures an outbound HTTP endpoint that receives notification events
+type Webhook struct {
+	URL     string `yaml:"url"`
+	Secret  string `yaml:"secret"`  // used to sign the payload with HMAC-SHA256
+	Timeout string `yaml:"timeout"` // defaults to 10s
 }
 
 // GlobalLimits defines cluster-wide resource constraints for all tenama-managed namespaces
 type GlobalLimits struct {
 	Enabled   bool      `yaml:"enabled"`
 	Resources Resources `yaml:"resources"`
+	// Percentage of its resources a hibernated namespace counts against the limits, defaults to 100
+	HibernatedCapacity *int `yaml:"hibernatedCapacity"`
+}
+
+// HibernationConfig enables scheduled hibernation and defines the default schedule
+// for namespaces that do not request their own
+type HibernationConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Hibernate string `yaml:"hibernate"` // cron expression, e.g. "0 19 * * 1-5"
+	WakeUp    string `yaml:"wakeUp"`    // cron expression, e.g. "0 7 * * 1-5"
+	TimeZone  string `yaml:"timeZone"`  // IANA time zone, defaults to UTC
+}
+
+// NamespaceTemplate is a named set of namespace settings that can be selected per request
+type NamespaceTemplate struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Resources   *Resources        `yaml:"resources"`   // quota of the namespace, defaults to namespace.resources
+	LimitRange  *LimitRange       `yaml:"limitRange"`  // default and bounds for the resources of containers
+	Labels      map[string]string `yaml:"labels"`      // added to the namespace
+	Annotations map[string]string `yaml:"annotations"` // added to the namespace
+	PodSecurity string            `yaml:"podSecurity"` // enforced Pod Security Standard level, defaults to "baseline"
+	Role        string            `yaml:"role"`        // ClusterRole bound to the requested users, defaults to "edit"
+	Duration    string            `yaml:"duration"`    // used if a request does not specify a duration
+	Users       []string          `yaml:"users"`       // users allowed to select the template, empty allows everyone
+}
+
+// BundleConfig sets the manifest bundle that is created in every new namespace. The
+// manifests are Go templates with the variables .Namespace, .Prefix, .Infix, .Creator,
+// .Template and .ExpiresAt.
+type BundleConfig struct {
+	Directory string        `yaml:"directory"` // all *.yaml and *.yml files, read on startup
+	ConfigMap *ConfigMapRef `yaml:"configMap"` // all *.yaml and *.yml keys, read on every creation
+}
+
+// ConfigMapRef references a ConfigMap by namespace and name
+type ConfigMapRef struct {
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+}
+
+// CopyConfig lists the Secrets and ConfigMaps that are copied into every new namespace
+type CopyConfig struct {
+	Secrets    []CopySource `yaml:"secrets"`
+	ConfigMaps []CopySource `yaml:"configMaps"`
+	// Add the copied image pull secrets to the tenama-sa and default ServiceAccounts
+	ImagePullSecrets bool `yaml:"imagePullSecrets"`
+}
+
+// CopySource selects objects of a namespace either by name or by labels
+type CopySource struct {
+	Namespace string            `yaml:"namespace"`
+	Name      string            `yaml:"name"`
+	Selector  map[string]string `yaml:"selector"`
+}
+
+// PodSecurityConfig defines which Pod Security Standard levels requests may enforce.
+// The default level of the namespace is always allowed.
+type PodSecurityConfig struct {
+	Allowed []string           `yaml:"allowed"` // levels every user may request
+	Grants  []PodSecurityGrant `yaml:"grants"`
+}
+
+// PodSecurityGrant allows additional levels for a user or for all members of a group
+type PodSecurityGrant struct {
+	Username string   `yaml:"username"`
+	Group    string   `yaml:"group"`
+	Allowed  []string `yaml:"allowed"`
+}
+
+// NetworkPolicyConfig denies ingress from other namespaces except for the allowed peers
+type NetworkPolicyConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	AllowFrom     []NetworkPeer `yaml:"allowFrom"`     // e.g. ingress controllers and monitoring
+	SameRequester bool          `yaml:"sameRequester"` // allow traffic between namespaces created by the same user
+	Egress        EgressConfig  `yaml:"egress"`
+}
+
+// EgressConfig optionally restricts the outgoing traffic of new namespaces
+type EgressConfig struct {
+	Restricted bool          `yaml:"restricted"` // only allow traffic within the namespace, to DNS and to allowTo
+	DNS        *NetworkPeer  `yaml:"dns"`        // defaults to kube-dns in kube-system
+	AllowTo    []NetworkPeer `yaml:"allowTo"`
+}
+
+// NetworkPeer selects pods by namespace and pod labels, or an IP range
+type NetworkPeer struct {
+	Name              string            `yaml:"name"` // only used for documentation and error messages
+	NamespaceSelector map[string]string `yaml:"namespaceSelector"`
+	PodSelector       map[string]string `yaml:"podSelector"`
+	CIDR              string            `yaml:"cidr"` // mutually exclusive with the selectors
+}
+
+// LimitRangeConfig enables the LimitRange for namespaces whose template does not define one
+type LimitRangeConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	LimitRange `yaml:",inline"`
+}
+
+// LimitRange defines the default resources and their bounds per container and per PVC
+type LimitRange struct {
+	Default               ContainerResources `yaml:"default"`        // limits of containers that do not set them
+	DefaultRequest        ContainerResources `yaml:"defaultRequest"` // requests of containers that do not set them
+	Min                   ContainerResources `yaml:"min"`
+	Max                   ContainerResources `yaml:"max"`
+	PersistentVolumeClaim StorageBounds      `yaml:"persistentVolumeClaim"`
+}
+
+// StorageBounds limits the requested storage of a single PersistentVolumeClaim
+type StorageBounds struct {
+	Min string `yaml:"min"`
+	Max string `yaml:"max"`
+}
+
+// ContainerResources are the cpu and memory values of a LimitRange
+type ContainerResources struct {
+	CPU    string `yaml:"cpu"`
+	Memory string `yaml:"memory"`
+}
+
+// DurationPolicy bounds the lifetime that can be requested on creation, extension and restore
+type DurationPolicy struct {
+	Default string               `yaml:"default"` // defaults to namespace.duration
+	Min     string               `yaml:"min"`
+	Max     string               `yaml:"max"`
+	Users   []UserDurationPolicy `yaml:"users"`
+}
+
+// UserDurationPolicy overrides the duration policy for a single user
+type UserDurationPolicy struct {
+	Username string `yaml:"username"`
+	Default  string `yaml:"default"`
+	Min      string `yaml:"min"`
+	Max      string `yaml:"max"`
+}
+
+// Extension defines the guardrails for extending the lifetime of a running namespace
+type Extension struct {
+	Enabled       bool   `yaml:"enabled"`
+	MaxLifetime   string `yaml:"maxLifetime"`   // upper bound for the total lifetime since creation, empty means unbounded
+	MaxExtensions int    `yaml:"maxExtensions"` // 0 means unbounded
 }
 
 type Resources struct {
@@ -34,7 +259,15 @@ type Resources struct {
 	} `yaml:"limits"`
 }
 
+// ResourceMaximums bound the resources requested for a single namespace, empty values are unbounded
+type ResourceMaximums struct {
+	CPU     string `yaml:"cpu"`
+	Memory  string `yaml:"memory"`
+	Storage string `yaml:"storage"`
+}
+
 type BasicAuth []struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	Groups   []string `yaml:"groups"` // used to grant permissions to several users at once
 }
@@ -0,0 +1,10 @@
+package models
+
+type GetNamespaceGroup200Response struct {
+	Message    string   `json:"message"`
+	Group      string   `json:"group,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	ExpiresAt  string   `json:"expiresAt,omitempty"`
+	// KubeConfigs holds the renewed kubeconfigs of an extended or restored group
+	KubeConfigs map[string][]byte `json:"kubeconfigs,omitempty"`
+}
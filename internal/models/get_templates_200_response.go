@@ -0,0 +1,16 @@
+package models
+
+type GetTemplates200Response struct {
+	Message   string     `json:"message"`
+	Templates []Template `json:"templates"`
+}
+
+// Template describes a namespace template that can be selected per request
+type Template struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	PodSecurity string            `json:"podSecurity"`
+	Role        string            `json:"role"`
+	Duration    string            `json:"duration,omitempty"`
+	Quota       map[string]string `json:"quota,omitempty"`
+}
@@ -11,13 +11,45 @@ type Namespace struct {
 	Suffix string `json:"suffix,omitempty"`
 
 	// How long should the namespace be preserved until it becomes obsolete and is automatically cleaned up.
+	// Either a duration such as "24h", "7d" or "1w2d", or an absolute RFC3339 timestamp.
 	Duration string `json:"duration,omitempty"`
 
-	// A list of users to be authorized as editors in this namespace.
-	Users []string `json:"users,omitempty"`
+	// A list of users, groups or service accounts to be authorized in this namespace,
+	// as editors or with the role of the template unless they select another role.
+	Users []NamespaceUser `json:"users,omitempty"`
 
 	// Optional: Resource requests for this namespace (cpu, memory, storage)
 	Resources *ResourceRequest `json:"resources,omitempty"`
+
+	// Optional: Name of the configured template to create the namespace from
+	Template string `json:"template,omitempty"`
+
+	// Optional: Pod Security Standard levels of the namespace
+	PodSecurity *PodSecurity `json:"podSecurity,omitempty"`
+
+	// Optional: Schedule for scaling the workloads of this namespace to zero outside working hours
+	Hibernation *Hibernation `json:"hibernation,omitempty"`
+}
+
+// PodSecurity selects the Pod Security Standard level per admission mode,
+// each one of "privileged", "baseline" or "restricted"
+type PodSecurity struct {
+	// Level that is enforced, defaults to the level of the template or "baseline"
+	Enforce string `json:"enforce,omitempty"`
+	// Level at which users are warned about violations
+	Warn string `json:"warn,omitempty"`
+	// Level at which violations are recorded in the audit log
+	Audit string `json:"audit,omitempty"`
+}
+
+// Hibernation defines when the workloads of a namespace are scaled to zero and back up again
+type Hibernation struct {
+	// Cron expression at which the namespace is hibernated, e.g. "0 19 * * 1-5"
+	Hibernate string `json:"hibernate"`
+	// Cron expression at which the namespace wakes up again, e.g. "0 7 * * 1-5"
+	WakeUp string `json:"wakeUp"`
+	// IANA time zone the cron expressions are evaluated in, defaults to UTC
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 // ResourceRequest defines requested and limited resources for a namespace
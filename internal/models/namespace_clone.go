@@ -0,0 +1,14 @@
+package models
+
+type NamespaceClone struct {
+	// Infix of the clone, defaults to the infix of the cloned namespace.
+	Infix string `json:"infix,omitempty"`
+
+	Suffix string `json:"suffix,omitempty"`
+
+	// How long should the clone be preserved, defaults to the lifetime of the cloned namespace.
+	Duration string `json:"duration,omitempty"`
+
+	// Also copy the PersistentVolumeClaims, without their data.
+	PersistentVolumeClaims bool `json:"persistentVolumeClaims,omitempty"`
+}
@@ -0,0 +1,6 @@
+package models
+
+type NamespaceExtension struct {
+	// How much time should be added to the remaining lifetime of the namespace.
+	Duration string `json:"duration"`
+}
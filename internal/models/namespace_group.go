@@ -0,0 +1,14 @@
+package models
+
+// NamespaceGroup requests several namespaces that are created, extended and deleted together
+type NamespaceGroup struct {
+	// The namespaces of the group, each one like a single namespace request. Their
+	// durations are ignored, all namespaces of a group expire together.
+	Namespaces []Namespace `json:"namespaces"`
+
+	// How long the namespaces of the group should be preserved, like the duration of a single namespace
+	Duration string `json:"duration,omitempty"`
+
+	// Optional: Return one kubeconfig with a context per namespace instead of a kubeconfig per namespace
+	SharedKubeconfig bool `json:"sharedKubeconfig,omitempty"`
+}
@@ -0,0 +1,6 @@
+package models
+
+type NamespaceRestore struct {
+	// How long should the restored namespace be preserved, defaults to the configured namespace duration.
+	Duration string `json:"duration,omitempty"`
+}
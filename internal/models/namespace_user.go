@@ -0,0 +1,27 @@
+package models
+
+import "encoding/json"
+
+// NamespaceUser is a subject that is granted access to a namespace. A plain string
+// is accepted as well and stands for a user with the default role.
+type NamespaceUser struct {
+	// Name of the user, group or service account
+	Name string `json:"name"`
+	// One of "User", "Group" or "ServiceAccount", defaults to "User"
+	Kind string `json:"kind,omitempty"`
+	// Namespace of a service account, not set for users and groups
+	Namespace string `json:"namespace,omitempty"`
+	// ClusterRole the subject is bound to, defaults to the role of the template
+	Role string `json:"role,omitempty"`
+}
+
+// UnmarshalJSON accepts either a username or a subject object
+func (u *NamespaceUser) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*u = NamespaceUser{Name: name}
+		return nil
+	}
+	type subject NamespaceUser
+	return json.Unmarshal(data, (*subject)(u))
+}
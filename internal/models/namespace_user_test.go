@@ -0,0 +1,23 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNamespaceUserUnmarshal(t *testing.T) {
+	var ns Namespace
+	body := `{"users":["jane",{"name":"qa","kind":"Group","role":"view"}]}`
+	if err := json.Unmarshal([]byte(body), &ns); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	want := []NamespaceUser{{Name: "jane"}, {Name: "qa", Kind: "Group", Role: "view"}}
+	if !reflect.DeepEqual(ns.Users, want) {
+		t.Errorf("Expected %+v, got %+v", want, ns.Users)
+	}
+
+	if err := json.Unmarshal([]byte(`{"users":[42]}`), &ns); err == nil {
+		t.Error("Expected error for an invalid user")
+	}
+}
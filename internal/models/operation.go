@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Operation reports the progress of an asynchronous namespace creation
+type Operation struct {
+	ID        string          `json:"id"`
+	Status    string          `json:"status"` // pending, running, succeeded or failed
+	Namespace string          `json:"namespace"`
+	Steps     []OperationStep `json:"steps"`
+	Message   string          `json:"message,omitempty"`
+	// KubeConfig is only set once the operation has succeeded
+	KubeConfig []byte               `json:"kubeconfig,omitempty"`
+	Bundle     []BundleObjectStatus `json:"bundle,omitempty"`
+	Cloned     []string             `json:"cloned,omitempty"`
+	CreatedAt  time.Time            `json:"createdAt"`
+	FinishedAt *time.Time           `json:"finishedAt,omitempty"`
+	// ExpiresAt is the time after which a finished operation is forgotten
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// OperationStep is the state of a single provisioning step
+type OperationStep struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // pending, running, succeeded, failed or rolledBack
+}
@@ -0,0 +1,11 @@
+package models
+
+type PatchNamespaceExtend200Response struct {
+	Message    string `json:"message"`
+	Namespace  string `json:"namespace,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+	Extensions int    `json:"extensions"`
+	ExpiresAt  string `json:"expiresAt,omitempty"`
+	// KubeConfig holds a token that expires with the extended lifetime
+	KubeConfig []byte `json:"kubeconfig,omitempty"`
+}
@@ -5,4 +5,12 @@ type PostNamespace200Response struct {
 	Namespace  string   `json:"namespace,omitempty"`
 	Namespaces []string `json:"namespaces,omitempty"`
 	KubeConfig []byte   `json:"kubeconfig,omitempty"`
+	// Group is the ID of the namespace group the namespaces were created in
+	Group string `json:"group,omitempty"`
+	// KubeConfigs holds the kubeconfig of each namespace of a group without a shared kubeconfig
+	KubeConfigs map[string][]byte `json:"kubeconfigs,omitempty"`
+	// Bundle reports the objects of the manifest bundle, failed objects do not fail the creation
+	Bundle []BundleObjectStatus `json:"bundle,omitempty"`
+	// Cloned lists the objects copied from the cloned namespace as kind/name
+	Cloned []string `json:"cloned,omitempty"`
 }
@@ -0,0 +1,9 @@
+package models
+
+type PostNamespace202Response struct {
+	Message string `json:"message"`
+	// Namespace is provisional, a taken random name is replaced and the final name is
+	// reported by the operation
+	Namespace   string `json:"namespace"`
+	OperationID string `json:"operationId"`
+}
@@ -0,0 +1,10 @@
+package models
+
+type PostNamespaceRestore200Response struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace,omitempty"`
+	Duration  string `json:"duration,omitempty"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	// KubeConfig holds a token that expires with the new lease
+	KubeConfig []byte `json:"kubeconfig,omitempty"`
+}